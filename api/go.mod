@@ -1,10 +0,0 @@
-module go-chatroom/api
-
-go 1.18
-
-require (
-	github.com/gorilla/websocket v1.5.0
-	go-chatroom v0.0.0
-)
-
-replace go-chatroom => ../
@@ -2,54 +2,118 @@ package main
 
 import (
 	"bufio"
-	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"go-chatroom/pkg/chat"
+	"go-chatroom/pkg/client"
+	"go-chatroom/pkg/config"
 	"go-chatroom/pkg/entity/model"
 	"go-chatroom/pkg/enum"
-	"net"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 )
 
+// typing 是否显示对方的输入状态，并在输入私聊和群聊内容时通知对方
+var typing bool
+
 func main() {
-	// 连接地址
-	host := "localhost"
-	// 连接端口
-	port := "8000"
+	// 加载配置，服务端地址可通过 -server 参数、CHATROOM_SERVER 环境变量或配置文件指定
+	cfg, err := config.Parse(flag.CommandLine, os.Args[1:], config.ClientFlags)
+	if err != nil {
+		fmt.Println("加载配置失败:", err)
+		return
+	}
+	typing = cfg.Client.Typing
 	// 拨号创建连接
-	conn, err := net.Dial("tcp", host+":"+port)
+	cli, err := client.Connect(cfg.Client.ServerAddr, cfg.Client.TLS)
 	if err != nil {
 		fmt.Println("连接服务器失败:", err)
 		return
 	}
 	// 连接后通过 defer 以防忘记关闭连接
-	defer conn.Close()
-	fmt.Println("已连接到", conn.RemoteAddr())
+	defer cli.Close()
+	fmt.Println("已连接到", cli.RemoteAddr())
 
-	//	定义基础信息，输入用户昵称
-	var baseMsg model.Message
-	fmt.Println("请输入用户昵称：")
-	_, err = fmt.Scanln(&baseMsg.Name)
-	if err != nil {
-		fmt.Println("读取用户昵称失败:", err)
-		return
-	}
-	fmt.Println("用户昵称为：", baseMsg.Name)
+	// 登录前后收到的事件都直接显示，显示过的私聊消息视为已读
+	cli.OnEvent(func(event model.Event) {
+		if event.Kind == enum.TypingEvent && !typing {
+			return
+		}
+		fmt.Println(chat.Render(event))
+		if event.Kind == enum.ChatEvent && event.Area == enum.PrivateArea && event.Name != cli.Name() {
+			if err := cli.MarkRead(event.Name, event.ID); err != nil {
+				fmt.Println("发送已读回执失败:", err)
+			}
+		}
+	})
 
-	go Receive(conn)
+	// 输入用户昵称和密码，登录成功后服务端会将昵称与当前连接绑定
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Println("1 - 登录  2 - 注册新账号")
+		fmt.Print("请选择: ")
+		if !scanner.Scan() {
+			return
+		}
+		register := strings.TrimSpace(scanner.Text()) == "2"
 
-	// Send initial login message
-	loginMsg := model.Message{
-		Name: baseMsg.Name,
-		Op:   enum.Login,
-		Msg:  "",
-		Area: enum.PublicScreen,
+		fmt.Println("请输入用户昵称：")
+		if !scanner.Scan() {
+			fmt.Println("读取用户昵称失败:", scanner.Err())
+			return
+		}
+		name := strings.TrimSpace(scanner.Text())
+
+		fmt.Println("请输入密码：")
+		if !scanner.Scan() {
+			fmt.Println("读取密码失败:", scanner.Err())
+			return
+		}
+		password := scanner.Text()
+
+		if register {
+			err = cli.Register(name, password)
+			if err != nil {
+				if !printRejected(err) {
+					fmt.Println("注册失败:", err)
+					return
+				}
+				continue
+			}
+			fmt.Println("注册成功")
+		}
+
+		ack, err := cli.Login(name, password)
+		if err == nil {
+			fmt.Println(chat.Render(ack))
+			break
+		}
+		if !printRejected(err) {
+			fmt.Println("登录失败:", err)
+			return
+		}
+	}
+	fmt.Println("用户昵称为：", cli.Name())
+
+	// 断线后自动重连，重新登录后服务端会补发断线期间的消息
+	if cfg.Client.Reconnect {
+		cli.EnableReconnect(client.Reconnect{
+			MinDelay: client.DefaultReconnect.MinDelay,
+			MaxDelay: client.DefaultReconnect.MaxDelay,
+			OnRetry: func(attempt int, delay time.Duration, err error) {
+				fmt.Printf("与服务器断开连接(%v)，%v 后进行第 %d 次重连...\n", err, delay, attempt)
+			},
+		})
 	}
-	Login(conn, loginMsg)
 
-	// 向服务端发送信息
-	scanner := bufio.NewScanner(os.Stdin)
+	// 连接断开后提示用户
+	go func() {
+		<-cli.Done()
+		fmt.Printf("接收数据失败: %v\n", cli.Err())
+	}()
 
 	// 显示菜单选项
 	showMenu()
@@ -61,50 +125,94 @@ func main() {
 		}
 		input := strings.TrimSpace(scanner.Text())
 
-		var msg = baseMsg
-
+		var err error
 		switch input {
 		case "1":
 			// 发送公屏消息
-			msg.Op = enum.Chat
-			msg.Area = enum.PublicScreen
-			Say(conn, msg)
+			err = Say(cli, scanner)
 		case "2":
 			// 发送私聊消息
-			msg.Op = enum.PrivateChat
-			msg.Area = enum.PrivateArea
-			SendPrivateMessage(conn, msg, scanner)
+			err = SendPrivateMessage(cli, scanner)
 		case "3":
 			// 发送群聊消息
-			msg.Op = enum.GroupChat
-			msg.Area = enum.GroupArea
-			SendGroupMessage(conn, msg, scanner)
+			err = SendGroupMessage(cli, scanner)
 		case "4":
 			// 创建群组
-			msg.Op = enum.CreateGroup
-			CreateGroup(conn, msg, scanner)
+			err = CreateGroup(cli, scanner)
 		case "5":
 			// 列出群组
-			msg.Op = enum.ListGroups
-			ListGroups(conn, msg)
+			err = cli.ListGroups()
 		case "6":
 			// 列出在线用户
-			msg.Op = enum.ListUsers
-			ListUsers(conn, msg)
+			err = cli.ListUsers()
 		case "7":
 			// 更新用户信息
-			msg.Op = enum.UpdateUser
-			UpdUser(conn, msg, scanner)
+			err = UpdUser(cli, scanner)
 		case "8":
 			// 退出
-			Quit(conn, msg)
+			Quit(cli)
 			return
+		case "9":
+			// 加入群组
+			err = JoinGroup(cli, scanner)
+		case "10":
+			// 退出群组
+			err = LeaveGroup(cli, scanner)
+		case "11":
+			// 邀请用户加入群组
+			err = InviteToGroup(cli, scanner)
+		case "12":
+			// 查看群组成员
+			err = ListGroupMembers(cli, scanner)
+		case "13":
+			// 群组管理
+			err = ManageGroup(cli, scanner)
+		case "14":
+			// 查看历史消息
+			err = FetchHistory(cli, scanner)
+		case "15":
+			// 修改消息
+			err = EditMessage(cli, scanner)
+		case "16":
+			// 删除消息
+			err = DeleteMessage(cli, scanner)
+		case "17":
+			// 回复消息
+			err = Reply(cli, scanner)
+		case "18":
+			// 表情回应
+			err = React(cli, scanner, true)
+		case "19":
+			// 取消表情回应
+			err = React(cli, scanner, false)
 		default:
 			fmt.Println("输入无效，请选择正确的选项")
 			showMenu()
 		}
+		if err != nil {
+			fmt.Println("发送失败:", err)
+		}
+	}
+}
 
+// printRejected 显示服务端拒绝登录或注册的原因，err 不是拒绝结果时返回 false
+func printRejected(err error) bool {
+	var rejected *client.RejectedError
+	if !errors.As(err, &rejected) {
+		return false
 	}
+	fmt.Println(chat.Render(rejected.Event))
+	return true
+}
+
+// prompt 显示提示并读取一行输入，读取失败时显示 failure 并返回 false
+func prompt(scanner *bufio.Scanner, title, failure string) (string, bool) {
+	fmt.Print(title)
+	if !scanner.Scan() {
+		fmt.Println(failure)
+		return "", false
+	}
+	return scanner.Text(), true
 }
 
 func showMenu() {
@@ -117,171 +225,297 @@ func showMenu() {
 	fmt.Println("6 - 查看在线用户")
 	fmt.Println("7 - 修改个人信息")
 	fmt.Println("8 - 退出聊天室")
+	fmt.Println("9 - 加入群组")
+	fmt.Println("10 - 退出群组")
+	fmt.Println("11 - 邀请用户加入群组")
+	fmt.Println("12 - 查看群组成员")
+	fmt.Println("13 - 群组管理")
+	fmt.Println("14 - 查看历史消息")
+	fmt.Println("15 - 修改消息")
+	fmt.Println("16 - 删除消息")
+	fmt.Println("17 - 回复消息")
+	fmt.Println("18 - 表情回应")
+	fmt.Println("19 - 取消表情回应")
 	fmt.Println("=====================")
 }
 
-func Say(conn net.Conn, m model.Message) {
-	fmt.Print("请输入想要发送的内容: ")
-	scanner := bufio.NewScanner(os.Stdin)
-	if !scanner.Scan() {
-		fmt.Println("读取消息失败")
-		return
+func Say(cli *client.Client, scanner *bufio.Scanner) error {
+	msg, ok := prompt(scanner, "请输入想要发送的内容: ", "读取消息失败")
+	if !ok {
+		return nil
 	}
-	m.Msg = scanner.Text()
+	_, err := cli.Say(msg)
+	return err
+}
 
-	sendMessage(conn, m)
+func SendPrivateMessage(cli *client.Client, scanner *bufio.Scanner) error {
+	target, ok := prompt(scanner, "请输入目标用户名: ", "读取目标用户名失败")
+	if !ok {
+		return nil
+	}
+	notifyTyping(cli, enum.PrivateArea, strings.TrimSpace(target))
+	msg, ok := prompt(scanner, "请输入私聊内容: ", "读取消息失败")
+	if !ok {
+		return nil
+	}
+	_, err := cli.Whisper(strings.TrimSpace(target), msg)
+	return err
 }
 
-func SendPrivateMessage(conn net.Conn, m model.Message, scanner *bufio.Scanner) {
-	fmt.Print("请输入目标用户名: ")
-	if !scanner.Scan() {
-		fmt.Println("读取目标用户名失败")
-		return
+func SendGroupMessage(cli *client.Client, scanner *bufio.Scanner) error {
+	group, ok := prompt(scanner, "请输入群组名称: ", "读取群组名称失败")
+	if !ok {
+		return nil
 	}
-	m.Target = strings.TrimSpace(scanner.Text())
+	notifyTyping(cli, enum.GroupArea, strings.TrimSpace(group))
+	msg, ok := prompt(scanner, "请输入群聊内容: ", "读取消息失败")
+	if !ok {
+		return nil
+	}
+	_, err := cli.SendToGroup(strings.TrimSpace(group), msg)
+	return err
+}
 
-	fmt.Print("请输入私聊内容: ")
-	if !scanner.Scan() {
-		fmt.Println("读取消息失败")
+// notifyTyping 开始输入聊天内容时通知对方，命令行按行读取，只能在提示输入时发送一次
+func notifyTyping(cli *client.Client, area enum.Area, name string) {
+	if !typing {
 		return
 	}
-	m.Msg = scanner.Text()
+	if err := cli.Typing(area, name); err != nil {
+		fmt.Println("发送输入状态失败:", err)
+	}
+}
 
-	sendMessage(conn, m)
+func CreateGroup(cli *client.Client, scanner *bufio.Scanner) error {
+	group, ok := prompt(scanner, "请输入要创建的群组名称: ", "读取群组名称失败")
+	if !ok {
+		return nil
+	}
+	return cli.CreateGroup(strings.TrimSpace(group))
 }
 
-func SendGroupMessage(conn net.Conn, m model.Message, scanner *bufio.Scanner) {
-	fmt.Print("请输入群组名称: ")
-	if !scanner.Scan() {
-		fmt.Println("读取群组名称失败")
-		return
+func JoinGroup(cli *client.Client, scanner *bufio.Scanner) error {
+	group, ok := prompt(scanner, "请输入要加入的群组名称: ", "读取群组名称失败")
+	if !ok {
+		return nil
 	}
-	m.Group = strings.TrimSpace(scanner.Text())
+	return cli.JoinGroup(strings.TrimSpace(group))
+}
 
-	fmt.Print("请输入群聊内容: ")
-	if !scanner.Scan() {
-		fmt.Println("读取消息失败")
-		return
+func LeaveGroup(cli *client.Client, scanner *bufio.Scanner) error {
+	group, ok := prompt(scanner, "请输入要退出的群组名称: ", "读取群组名称失败")
+	if !ok {
+		return nil
 	}
-	m.Msg = scanner.Text()
+	return cli.LeaveGroup(strings.TrimSpace(group))
+}
 
-	sendMessage(conn, m)
+func InviteToGroup(cli *client.Client, scanner *bufio.Scanner) error {
+	group, ok := prompt(scanner, "请输入群组名称: ", "读取群组名称失败")
+	if !ok {
+		return nil
+	}
+	user, ok := prompt(scanner, "请输入被邀请的用户名: ", "读取用户名失败")
+	if !ok {
+		return nil
+	}
+	return cli.InviteToGroup(strings.TrimSpace(group), strings.TrimSpace(user))
 }
 
-func CreateGroup(conn net.Conn, m model.Message, scanner *bufio.Scanner) {
-	fmt.Print("请输入要创建的群组名称: ")
-	if !scanner.Scan() {
-		fmt.Println("读取群组名称失败")
-		return
+func ListGroupMembers(cli *client.Client, scanner *bufio.Scanner) error {
+	group, ok := prompt(scanner, "请输入群组名称: ", "读取群组名称失败")
+	if !ok {
+		return nil
 	}
-	m.Msg = strings.TrimSpace(scanner.Text())
+	return cli.ListGroupMembers(strings.TrimSpace(group))
+}
 
-	sendMessage(conn, m)
+// 群组管理操作，needTarget 表示是否需要输入目标用户
+var manageOps = []struct {
+	title      string
+	op         enum.Operation
+	needTarget bool
+}{
+	{"设为管理员", enum.PromoteAdmin, true},
+	{"取消管理员", enum.DemoteAdmin, true},
+	{"移出成员", enum.KickMember, true},
+	{"封禁用户", enum.BanMember, true},
+	{"解除封禁", enum.UnbanMember, true},
+	{"转让群主", enum.TransferGroup, true},
+	{"解散群组", enum.DissolveGroup, false},
 }
 
-func ListGroups(conn net.Conn, m model.Message) {
-	sendMessage(conn, m)
+func ManageGroup(cli *client.Client, scanner *bufio.Scanner) error {
+	for i, item := range manageOps {
+		fmt.Printf("%d - %s\n", i+1, item.title)
+	}
+	input, ok := prompt(scanner, "请选择管理操作: ", "读取操作失败")
+	if !ok {
+		return nil
+	}
+	choice, err := strconv.Atoi(strings.TrimSpace(input))
+	if err != nil || choice < 1 || choice > len(manageOps) {
+		fmt.Println("输入无效")
+		return nil
+	}
+	item := manageOps[choice-1]
+
+	group, ok := prompt(scanner, "请输入群组名称: ", "读取群组名称失败")
+	if !ok {
+		return nil
+	}
+
+	var target string
+	if item.needTarget {
+		target, ok = prompt(scanner, "请输入目标用户名: ", "读取用户名失败")
+		if !ok {
+			return nil
+		}
+	}
+
+	return cli.ManageGroup(item.op, strings.TrimSpace(group), strings.TrimSpace(target))
 }
 
-func ListUsers(conn net.Conn, m model.Message) {
-	sendMessage(conn, m)
+// promptArea 选择聊天区域，群聊时输入群组名称，私聊时输入对方用户名
+func promptArea(scanner *bufio.Scanner) (enum.Area, string, bool) {
+	input, ok := prompt(scanner, "请选择聊天区域 (1 - 公屏, 2 - 群聊, 3 - 私聊): ", "读取聊天区域失败")
+	if !ok {
+		return "", "", false
+	}
+	area := enum.PublicScreen
+	var name string
+	switch strings.TrimSpace(input) {
+	case "2":
+		area = enum.GroupArea
+		name, ok = prompt(scanner, "请输入群组名称: ", "读取群组名称失败")
+	case "3":
+		area = enum.PrivateArea
+		name, ok = prompt(scanner, "请输入私聊对象: ", "读取用户名失败")
+	}
+	return area, strings.TrimSpace(name), ok
 }
 
-func sendMessage(conn net.Conn, m model.Message) {
-	// 设置时间戳
-	m.Timestamp = time.Now().Unix()
+func FetchHistory(cli *client.Client, scanner *bufio.Scanner) error {
+	area, name, ok := promptArea(scanner)
+	if !ok {
+		return nil
+	}
 
-	msgData, err := json.Marshal(m)
-	if err != nil {
-		fmt.Println("json.Marshal error:", err)
-		return
+	var query model.HistoryQuery
+	input, ok := prompt(scanner, "请输入查询条数(默认20): ", "读取条数失败")
+	if !ok {
+		return nil
 	}
+	query.Limit, _ = strconv.Atoi(strings.TrimSpace(input))
 
-	_, err = conn.Write(msgData)
-	if err != nil {
-		fmt.Println("发送失败:", err)
-		return
+	input, ok = prompt(scanner, "只查看该消息ID之前的消息(直接回车查看最新): ", "读取消息ID失败")
+	if !ok {
+		return nil
 	}
-}
+	query.BeforeID, _ = strconv.ParseUint(strings.TrimSpace(input), 10, 64)
 
-func Quit(conn net.Conn, m model.Message) {
-	m.Op = enum.Logout
+	return cli.FetchHistory(area, name, query)
+}
 
-	msgData, err := json.Marshal(m)
-	if err != nil {
-		fmt.Println("json.Marshal error:", err)
-		return
+// promptID 读取消息ID，消息ID显示在每条聊天消息的最前面
+func promptID(scanner *bufio.Scanner, title string) (uint64, bool) {
+	input, ok := prompt(scanner, title, "读取消息ID失败")
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(strings.TrimPrefix(strings.TrimSpace(input), "#"), 10, 64)
+	if err != nil || id == 0 {
+		fmt.Println("消息ID无效")
+		return 0, false
 	}
+	return id, true
+}
 
-	_, err = conn.Write(msgData)
-	if err != nil {
-		fmt.Println("离线失败:", err)
-		return
+func EditMessage(cli *client.Client, scanner *bufio.Scanner) error {
+	id, ok := promptID(scanner, "请输入要修改的消息ID: ")
+	if !ok {
+		return nil
 	}
-	fmt.Println("离线成功")
+	msg, ok := prompt(scanner, "请输入新的内容: ", "读取消息失败")
+	if !ok {
+		return nil
+	}
+	return cli.EditMessage(id, msg)
 }
 
-func Receive(conn net.Conn) {
-	for {
-		data := make([]byte, 2048) // Increased buffer size
-		ml, err := conn.Read(data)
-		if ml == 0 || err != nil {
-			fmt.Printf("接收数据失败: %v\n", err)
-			return // Exit when connection is closed or error occurs
-		}
-		fmt.Print(string(data[:ml]))
+func DeleteMessage(cli *client.Client, scanner *bufio.Scanner) error {
+	id, ok := promptID(scanner, "请输入要删除的消息ID: ")
+	if !ok {
+		return nil
 	}
+	return cli.DeleteMessage(id)
 }
 
-func Login(conn net.Conn, m model.Message) {
-	// Login 即为我们本地维护的Op表
-	msgData, err := json.Marshal(m)
-	if err != nil {
-		fmt.Println("json.Marshal error:", err)
-		return
+func Reply(cli *client.Client, scanner *bufio.Scanner) error {
+	area, name, ok := promptArea(scanner)
+	if !ok {
+		return nil
 	}
-	_, err = conn.Write(msgData)
-	if err != nil {
-		fmt.Println("通知服务端登录信息发送失败:", err)
-		return
+	id, ok := promptID(scanner, "请输入要回复的消息ID: ")
+	if !ok {
+		return nil
 	}
+	msg, ok := prompt(scanner, "请输入回复内容: ", "读取消息失败")
+	if !ok {
+		return nil
+	}
+	_, err := cli.Reply(area, name, id, msg)
+	return err
 }
 
-func UpdUser(conn net.Conn, m model.Message, scanner *bufio.Scanner) {
-	var user model.User
-	fmt.Print("请输入想要的更新的用户年龄: ")
-	if !scanner.Scan() {
-		fmt.Println("读取年龄失败")
-		return
+// React 用表情回应消息，add 为 false 时取消回应
+func React(cli *client.Client, scanner *bufio.Scanner, add bool) error {
+	id, ok := promptID(scanner, "请输入消息ID: ")
+	if !ok {
+		return nil
+	}
+	emoji, ok := prompt(scanner, "请输入表情: ", "读取表情失败")
+	if !ok {
+		return nil
 	}
-	user.Age = scanner.Text()
+	emoji = strings.TrimSpace(emoji)
+	if add {
+		return cli.React(id, emoji)
+	}
+	return cli.Unreact(id, emoji)
+}
 
-	fmt.Print("请输入想要的更新的用户性别: ")
-	if !scanner.Scan() {
-		fmt.Println("读取性别失败")
+func Quit(cli *client.Client) {
+	if err := cli.Logout(); err != nil {
+		fmt.Println("离线失败:", err)
 		return
 	}
-	user.Sex = scanner.Text()
+	fmt.Println("离线成功")
+}
 
-	userData, err := json.Marshal(user)
-	if err != nil {
-		fmt.Println("json.Marshal user error:", err)
-		return
+func UpdUser(cli *client.Client, scanner *bufio.Scanner) error {
+	var user model.User
+	age, ok := prompt(scanner, "请输入想要的更新的用户年龄: ", "读取年龄失败")
+	if !ok {
+		return nil
 	}
+	user.Age = age
 
-	m.Msg = string(userData)
-	m.Op = enum.UpdateUser
+	sex, ok := prompt(scanner, "请输入想要的更新的用户性别: ", "读取性别失败")
+	if !ok {
+		return nil
+	}
+	user.Sex = sex
 
-	msgData, err := json.Marshal(m)
-	if err != nil {
-		fmt.Println("json.Marshal error:", err)
-		return
+	receipts, ok := prompt(scanner, "是否向私聊对象发送已读回执(Y/n): ", "读取设置失败")
+	if !ok {
+		return nil
 	}
+	user.HideReadReceipts = strings.EqualFold(strings.TrimSpace(receipts), "n")
 
-	_, err = conn.Write(msgData)
-	if err != nil {
-		fmt.Println("修改失败:", err)
-		return
+	if err := cli.UpdateProfile(user); err != nil {
+		return err
 	}
 	fmt.Println("修改成功")
+	return nil
 }
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go-chatroom/pkg/tlsutil"
+	"strings"
+	"time"
+)
+
+// 生成本地开发用的自签名证书
+func main() {
+	certFile := flag.String("cert", "server.crt", "证书输出路径")
+	keyFile := flag.String("key", "server.key", "私钥输出路径")
+	hosts := flag.String("hosts", "localhost,127.0.0.1", "证书中的域名或 IP，以逗号分隔")
+	days := flag.Int("days", 365, "有效天数")
+	flag.Parse()
+
+	var hostList []string
+	for _, h := range strings.Split(*hosts, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			hostList = append(hostList, h)
+		}
+	}
+
+	validFor := time.Duration(*days) * 24 * time.Hour
+	if err := tlsutil.GenerateSelfSigned(*certFile, *keyFile, hostList, validFor); err != nil {
+		fmt.Printf("生成证书失败！error:%v\n", err)
+		return
+	}
+	fmt.Printf("已生成证书 %s 和私钥 %s\n", *certFile, *keyFile)
+}
@@ -1,3 +1,8 @@
 module go-chatroom
 
 go 1.18
+
+require (
+	github.com/gorilla/websocket v1.5.0
+	golang.org/x/crypto v0.24.0
+)
@@ -0,0 +1,23 @@
+package account
+
+import "golang.org/x/crypto/bcrypt"
+
+// PasswordCost bcrypt 计算成本，哈希中保存了成本和盐，调高后只影响新设置的密码
+const PasswordCost = 12
+
+// hashPassword 使用 bcrypt 计算加盐的密码哈希
+func hashPassword(password string) (string, error) {
+	if len(password) > maxPasswordLength {
+		return "", ErrLongPassword
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), PasswordCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// checkPassword 校验密码与已保存的 bcrypt 哈希是否一致
+func checkPassword(password, hash string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
@@ -0,0 +1,54 @@
+package account
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHashPassword(t *testing.T) {
+	hash, err := hashPassword("secret")
+	if err != nil {
+		t.Fatalf("hashPassword: %v", err)
+	}
+	if cost, err := bcrypt.Cost([]byte(hash)); err != nil || cost != PasswordCost {
+		t.Fatalf("cost = %d, %v, want %d", cost, err, PasswordCost)
+	}
+	if !checkPassword("secret", hash) || checkPassword("wrong", hash) {
+		t.Fatal("bcrypt hash check mismatch")
+	}
+	if _, err := hashPassword(strings.Repeat("x", maxPasswordLength+1)); err != ErrLongPassword {
+		t.Fatalf("err = %v, want ErrLongPassword", err)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "accounts.json")
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	if err := s.Register("alice", "secret"); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if err := s.Register("alice", "other"); err != ErrExists {
+		t.Fatalf("err = %v, want ErrExists", err)
+	}
+
+	// 重新加载后密码仍然有效
+	s, err = NewFileStore(path)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	if _, err := s.Authenticate("alice", "wrong"); err != ErrBadPassword {
+		t.Fatalf("err = %v, want ErrBadPassword", err)
+	}
+	if _, err := s.Authenticate("bob", "secret"); err != ErrNotFound {
+		t.Fatalf("err = %v, want ErrNotFound", err)
+	}
+	if _, err := s.Authenticate("alice", "secret"); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+}
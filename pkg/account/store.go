@@ -0,0 +1,168 @@
+package account
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-chatroom/pkg/entity/model"
+	"go-chatroom/pkg/fsutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrExists        = errors.New("用户名已被注册")
+	ErrNotFound      = errors.New("账号不存在，请先注册")
+	ErrBadPassword   = errors.New("密码错误")
+	ErrEmptyName     = errors.New("用户名不能为空")
+	ErrEmptyPassword = errors.New("密码不能为空")
+	ErrLongPassword  = fmt.Errorf("密码不能超过 %d 字节", maxPasswordLength)
+)
+
+// maxPasswordLength bcrypt 只使用密码的前 72 字节，超出时直接拒绝
+const maxPasswordLength = 72
+
+// Account 已注册的账号
+type Account struct {
+	Name      string     `json:"name"`       // 用户名
+	Hash      string     `json:"hash"`       // bcrypt 密码哈希，包含成本和盐
+	CreatedAt int64      `json:"created_at"` // 注册时间
+	User      model.User `json:"user"`       // 用户资料
+}
+
+// Store 账号存储，可替换为其他实现（例如数据库）
+type Store interface {
+	// Register 注册新账号，用户名已存在时返回 ErrExists
+	Register(name, password string) error
+	// Authenticate 校验账号密码，成功时返回账号信息
+	Authenticate(name, password string) (Account, error)
+	// Exists 判断用户名是否已被注册
+	Exists(name string) bool
+	// UpdateUser 更新账号的用户资料
+	UpdateUser(name string, user model.User) error
+}
+
+// FileStore 以 JSON 文件保存账号的存储
+type FileStore struct {
+	path     string
+	mu       sync.RWMutex
+	accounts map[string]Account
+}
+
+// NewFileStore 从 path 加载账号，文件不存在时创建空的存储
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{
+		path:     path,
+		accounts: make(map[string]Account),
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+	if len(data) == 0 {
+		return s, nil
+	}
+
+	var accounts []Account
+	if err := json.Unmarshal(data, &accounts); err != nil {
+		return nil, err
+	}
+	for _, a := range accounts {
+		s.accounts[a.Name] = a
+	}
+	return s, nil
+}
+
+func (s *FileStore) Register(name, password string) error {
+	if strings.TrimSpace(name) == "" {
+		return ErrEmptyName
+	}
+	if password == "" {
+		return ErrEmptyPassword
+	}
+
+	// 计算哈希较慢，不在持有锁时进行
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.accounts[name]; exists {
+		return ErrExists
+	}
+	s.accounts[name] = Account{
+		Name:      name,
+		Hash:      hash,
+		CreatedAt: time.Now().Unix(),
+	}
+	if err := s.save(); err != nil {
+		delete(s.accounts, name)
+		return err
+	}
+	return nil
+}
+
+func (s *FileStore) Authenticate(name, password string) (Account, error) {
+	s.mu.RLock()
+	a, exists := s.accounts[name]
+	s.mu.RUnlock()
+
+	if !exists {
+		return Account{}, ErrNotFound
+	}
+	if !checkPassword(password, a.Hash) {
+		return Account{}, ErrBadPassword
+	}
+	return a, nil
+}
+
+func (s *FileStore) Exists(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, exists := s.accounts[name]
+	return exists
+}
+
+func (s *FileStore) UpdateUser(name string, user model.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, exists := s.accounts[name]
+	if !exists {
+		return ErrNotFound
+	}
+	old := a.User
+	a.User = user
+	s.accounts[name] = a
+	if err := s.save(); err != nil {
+		a.User = old
+		s.accounts[name] = a
+		return err
+	}
+	return nil
+}
+
+// save 将全部账号写入文件，调用方需持有写锁
+func (s *FileStore) save() error {
+	accounts := make([]Account, 0, len(s.accounts))
+	for _, a := range s.accounts {
+		accounts = append(accounts, a)
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Name < accounts[j].Name })
+	data, err := json.MarshalIndent(accounts, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(s.path, data)
+}
@@ -0,0 +1,168 @@
+package chat
+
+import (
+	"fmt"
+	"go-chatroom/pkg/entity/model"
+	"go-chatroom/pkg/enum"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Render 将服务端事件渲染为命令行展示的文本
+func Render(e model.Event) string {
+	t := time.Unix(e.Timestamp, 0).Format("2006-01-02 15:04:05")
+
+	switch e.Kind {
+	case enum.ChatEvent, enum.MessageUpdateEvent:
+		return ShowInOneArea(e.Area, renderChat(e, t))
+	case enum.LoginEvent:
+		return ShowInOneArea(enum.PublicScreen, fmt.Sprintf("%v [%s]: %v", t, e.Name, "I Login"))
+	case enum.LogoutEvent:
+		return ShowInOneArea(enum.PublicScreen, fmt.Sprintf("%v [%s]: %v", t, e.Name, "I Logout"))
+	case enum.LoginAckEvent:
+		text := fmt.Sprintf("%v 登录成功，当前身份 [%s]", t, e.Name)
+		if e.Pending > 0 {
+			text += fmt.Sprintf("，你有 %d 条离线消息", e.Pending)
+		}
+		if e.Truncated {
+			text += "，断线期间的消息过多，只补发了最近的部分，更早的消息请查询历史"
+		}
+		return ShowInOneArea(enum.PublicScreen, text)
+	case enum.LoginRejectEvent:
+		return ShowInOneArea(enum.PublicScreen, "登录失败: "+e.Msg)
+	case enum.RegisterAckEvent:
+		return ShowInOneArea(enum.PublicScreen, fmt.Sprintf("账号 [%s] 注册成功", e.Name))
+	case enum.RegisterRejectEvent:
+		return ShowInOneArea(enum.PublicScreen, "注册失败: "+e.Msg)
+	case enum.UserListEvent:
+		return ShowInOneArea(e.Area, renderList("当前在线用户列表:", "当前没有在线用户", e.List))
+	case enum.GroupListEvent:
+		return ShowInOneArea(e.Area, renderList("当前群组列表:", "当前没有可用的群组", e.List))
+	case enum.GroupMembersEvent:
+		members := make([]string, 0, len(e.List))
+		for _, name := range e.List {
+			members = append(members, fmt.Sprintf("%s (%s)", name, e.Roles[name].RoleName()))
+		}
+		return ShowInOneArea(e.Area, renderList(fmt.Sprintf("群组 %s 成员列表:", e.Group), fmt.Sprintf("群组 %s 没有成员", e.Group), members))
+	case enum.GroupJoinEvent:
+		if e.Name != e.Target {
+			return ShowInOneArea(e.Area, fmt.Sprintf("%v [%s] %s 邀请 %s 加入了群组", t, e.Group, e.Name, e.Target))
+		}
+		return ShowInOneArea(e.Area, fmt.Sprintf("%v [%s] %s 加入了群组", t, e.Group, e.Target))
+	case enum.GroupLeaveEvent:
+		if e.Name != e.Target {
+			return ShowInOneArea(e.Area, fmt.Sprintf("%v [%s] %s 被 %s 移出了群组", t, e.Group, e.Target, e.Name))
+		}
+		return ShowInOneArea(e.Area, fmt.Sprintf("%v [%s] %s 退出了群组", t, e.Group, e.Target))
+	case enum.GroupRoleEvent:
+		return ShowInOneArea(e.Area, fmt.Sprintf("%v [%s] %s 将 %s 设为%s", t, e.Group, e.Name, e.Target, enum.GroupRole(e.Msg).RoleName()))
+	case enum.GroupBanEvent:
+		return ShowInOneArea(e.Area, fmt.Sprintf("%v [%s] %s 被 %s 封禁", t, e.Group, e.Target, e.Name))
+	case enum.GroupUnbanEvent:
+		return ShowInOneArea(e.Area, fmt.Sprintf("%v [%s] %s 被 %s 解除封禁", t, e.Group, e.Target, e.Name))
+	case enum.GroupDissolveEvent:
+		return ShowInOneArea(e.Area, fmt.Sprintf("%v [%s] 群组已被 %s 解散", t, e.Group, e.Name))
+	case enum.HistoryEvent:
+		if len(e.History) == 0 {
+			return ShowInOneArea(e.Area, "没有更多历史消息")
+		}
+		lines := []string{ShowInOneArea(e.Area, fmt.Sprintf("历史消息 (%d 条):", len(e.History)))}
+		for _, record := range e.History {
+			lines = append(lines, Render(record))
+		}
+		return strings.Join(lines, "\n")
+	case enum.ProfileEvent:
+		var user model.User
+		if e.User != nil {
+			user = *e.User
+		}
+		return ShowInOneArea(e.Area, fmt.Sprintf("%v 用户[%s]: 用户信息 %v", t, e.Name, user))
+	case enum.ErrorEvent:
+		return ShowInOneArea(e.Area, "错误: "+e.Msg)
+	case enum.AckEvent:
+		switch e.Status {
+		case enum.StatusDelivered:
+			return ShowInOneArea(e.Area, fmt.Sprintf("消息 #%d 已送达 %d 人", e.ID, e.Delivered))
+		case enum.StatusFailed:
+			return ShowInOneArea(e.Area, "消息发送失败: "+e.Msg)
+		}
+		if e.Area == enum.PublicScreen {
+			return ShowInOneArea(e.Area, fmt.Sprintf("消息 #%d 已发送，当前没有其他在线用户", e.ID))
+		}
+		return ShowInOneArea(e.Area, fmt.Sprintf("消息 #%d 已发送，接收者上线后送达", e.ID))
+	case enum.ReadReceiptEvent:
+		return ShowInOneArea(e.Area, fmt.Sprintf("%v %s 已读你发送的私聊消息 #%d 及之前的消息", t, e.Name, e.ID))
+	case enum.ReactionEvent:
+		if len(e.Reactions) == 0 {
+			return ShowInOneArea(e.Area, fmt.Sprintf("消息 #%d 已没有表情回应", e.ID))
+		}
+		return ShowInOneArea(e.Area, fmt.Sprintf("%s 更新了对消息 #%d 的回应: %s", e.Name, e.ID, renderReactions(e.Reactions)))
+	case enum.TypingEvent:
+		if e.Area == enum.GroupArea {
+			return ShowInOneArea(e.Area, fmt.Sprintf("[%s] %s 正在输入…", e.Group, e.Name))
+		}
+		return ShowInOneArea(e.Area, fmt.Sprintf("%s 正在输入…", e.Name))
+	case enum.ShutdownEvent:
+		return ShowInOneArea(enum.PublicScreen, fmt.Sprintf("%v 系统通知: %s", t, e.Msg))
+	default:
+		return ShowInOneArea(e.Area, e.Msg)
+	}
+}
+
+// renderChat 渲染聊天消息，带有消息ID时显示在最前面，便于修改、删除和引用
+func renderChat(e model.Event, t string) string {
+	text := e.Msg
+	switch {
+	case e.Deleted:
+		text = fmt.Sprintf("[消息已被 %s 删除]", e.DeletedBy)
+	case e.EditedAt != 0:
+		text += " (已编辑)"
+	}
+
+	var line string
+	switch e.Area {
+	case enum.PrivateArea:
+		line = fmt.Sprintf("%v [%s -> %s]: %v", t, e.Name, e.Target, text)
+	case enum.GroupArea:
+		line = fmt.Sprintf("%v [%s]-%s: %v", t, e.Group, e.Name, text)
+	default:
+		line = fmt.Sprintf("%v [%s]: %v", t, e.Name, text)
+	}
+	if e.ID > 0 {
+		line = fmt.Sprintf("#%d %s", e.ID, line)
+	}
+	if len(e.Reactions) > 0 {
+		line += "  [" + renderReactions(e.Reactions) + "]"
+	}
+	if e.Quote != nil {
+		line += fmt.Sprintf("\n    ↳ 回复 #%d [%s]: %s", e.ReplyTo, e.Quote.Name, e.Quote.Msg)
+	}
+	return line
+}
+
+// renderReactions 按表情排序后显示每种表情的回应人数
+func renderReactions(reactions map[string][]string) string {
+	emojis := make([]string, 0, len(reactions))
+	for emoji := range reactions {
+		emojis = append(emojis, emoji)
+	}
+	sort.Strings(emojis)
+
+	parts := make([]string, 0, len(emojis))
+	for _, emoji := range emojis {
+		parts = append(parts, fmt.Sprintf("%s %d", emoji, len(reactions[emoji])))
+	}
+	return strings.Join(parts, " ")
+}
+
+func renderList(title, empty string, items []string) string {
+	if len(items) == 0 {
+		return empty
+	}
+	lines := []string{title}
+	for _, item := range items {
+		lines = append(lines, "- "+item)
+	}
+	return strings.Join(lines, "\n")
+}
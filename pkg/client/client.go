@@ -0,0 +1,639 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"go-chatroom/pkg/config"
+	"go-chatroom/pkg/entity/model"
+	"go-chatroom/pkg/enum"
+	"go-chatroom/pkg/protocol"
+	"go-chatroom/pkg/tlsutil"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// EventBuffer 事件通道的缓冲长度
+const EventBuffer = 256
+
+const (
+	// ReadTimeout 超过该时间没有收到服务端的任何数据时认为连接已断开（例如服务端主机宕机而没有断开连接），
+	// 开启重连时随之重连。服务端默认每 30 秒发送一次心跳
+	ReadTimeout = 90 * time.Second
+	// PingInterval 超过该时间没有收到任何数据时主动发送 Ping，服务端关闭心跳时连接也不会因空闲而超时
+	PingInterval = 30 * time.Second
+)
+
+var (
+	// ErrNotLoggedIn 登录之前调用了需要登录的方法
+	ErrNotLoggedIn = errors.New("client: not logged in")
+	// ErrLoggedIn 重复登录
+	ErrLoggedIn = errors.New("client: already logged in")
+	// ErrClosed 客户端已关闭
+	ErrClosed = errors.New("client: closed")
+)
+
+// RejectedError 服务端拒绝登录或注册，Event 为服务端返回的事件，Msg 为原因
+type RejectedError struct {
+	Event model.Event
+}
+
+func (e *RejectedError) Error() string {
+	return e.Event.Msg
+}
+
+// Client 聊天室客户端，封装了协议细节，可用于命令行客户端、机器人和测试
+// 登录成功后服务端推送的事件通过 Events 通道或 OnEvent 回调获取
+type Client struct {
+	dial func() (net.Conn, error) // 重新建立连接，New 创建的客户端为空，不支持重连
+
+	writeMu sync.Mutex // 保证同一时刻只有一个协程写连接
+
+	mu        sync.Mutex
+	conn      net.Conn          // 当前连接，重连后会被替换
+	enc       *protocol.Encoder // 当前连接的编码器
+	name      string            // 登录成功后的用户名
+	password  string            // 重连时重新登录使用
+	handler   func(model.Event) // 事件回调，设置后不再写入事件通道
+	loggedIn  bool
+	closed    bool       // 调用过 Close 或 Logout，不再重连
+	lastID    uint64     // 最后收到的消息ID，重连时据此补发断线期间的消息
+	seq       uint64     // 最后生成的 CorrelationID
+	lastRead  time.Time  // 最后一次收到服务端数据的时间
+	reconnect *Reconnect // 断线重连参数，为空时不重连
+
+	closing chan struct{} // Close 时关闭，用于打断重连等待
+	events  chan model.Event
+	results chan model.Event // 注册和登录的结果
+	done    chan struct{}    // 接收协程退出后关闭
+	err     error            // 接收协程退出的原因
+}
+
+// Connect 连接到服务端，tlsCfg.Enabled 为 true 时使用 TLS
+func Connect(addr string, tlsCfg config.TLS) (*Client, error) {
+	dial := func() (net.Conn, error) {
+		return tlsutil.Dial(addr, tlsCfg)
+	}
+	conn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	c := newClient(conn)
+	c.dial = dial
+	go c.receive(protocol.NewDecoder(conn, protocol.DefaultMaxFrameSize))
+	go c.keepalive()
+	return c, nil
+}
+
+// New 使用已经建立的连接创建客户端，并立即开始在后台接收事件
+// 登录之前也会自动回复服务端的心跳，等待用户输入时不会被服务端断开
+func New(conn net.Conn) *Client {
+	c := newClient(conn)
+	go c.receive(protocol.NewDecoder(conn, protocol.DefaultMaxFrameSize))
+	go c.keepalive()
+	return c
+}
+
+func newClient(conn net.Conn) *Client {
+	return &Client{
+		conn:     conn,
+		enc:      protocol.NewEncoder(conn),
+		lastRead: time.Now(),
+		closing:  make(chan struct{}),
+		events:   make(chan model.Event, EventBuffer),
+		results:  make(chan model.Event, 1),
+		done:     make(chan struct{}),
+	}
+}
+
+// RemoteAddr 返回服务端地址
+func (c *Client) RemoteAddr() net.Addr {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn.RemoteAddr()
+}
+
+// LastID 返回最后收到的消息ID
+func (c *Client) LastID() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastID
+}
+
+// Name 返回登录的用户名，未登录时为空
+func (c *Client) Name() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.name
+}
+
+// Events 返回服务端推送的事件，连接断开后通道会被关闭，断开原因见 Err
+// 设置了 OnEvent 回调时事件只交给回调，不再写入该通道
+func (c *Client) Events() <-chan model.Event {
+	return c.events
+}
+
+// OnEvent 设置事件回调，回调在接收协程中依次执行，应当在 Login 之前设置
+func (c *Client) OnEvent(fn func(model.Event)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handler = fn
+}
+
+// Done 返回一个在连接断开后关闭的通道
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err 返回连接断开的原因，连接仍然正常时为空
+func (c *Client) Err() error {
+	select {
+	case <-c.done:
+		return c.err
+	default:
+		return nil
+	}
+}
+
+// Register 注册新账号，注册成功后仍需调用 Login
+// 服务端拒绝时返回 *RejectedError
+func (c *Client) Register(name, password string) error {
+	if c.isLoggedIn() {
+		return ErrLoggedIn
+	}
+	err := c.Send(model.Message{
+		Name: name,
+		Op:   enum.Register,
+		Msg:  password,
+		Area: enum.PublicScreen,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = c.waitResult(enum.RegisterAckEvent, enum.RegisterRejectEvent)
+	return err
+}
+
+// Login 登录并返回服务端的 login_ack 事件，其中 Pending 为随后推送的离线消息条数
+// 服务端拒绝时返回 *RejectedError
+func (c *Client) Login(name, password string) (model.Event, error) {
+	if c.isLoggedIn() {
+		return model.Event{}, ErrLoggedIn
+	}
+	err := c.Send(model.Message{
+		Name: name,
+		Op:   enum.Login,
+		Msg:  password,
+		Area: enum.PublicScreen,
+	})
+	if err != nil {
+		return model.Event{}, err
+	}
+	ack, err := c.waitResult(enum.LoginAckEvent, enum.LoginRejectEvent)
+	if err != nil {
+		return model.Event{}, err
+	}
+
+	c.mu.Lock()
+	c.password = password
+	c.mu.Unlock()
+	return ack, nil
+}
+
+// Say 发送公屏消息，返回消息的 CorrelationID，投递结果通过 Ack 事件返回
+func (c *Client) Say(msg string) (string, error) {
+	return c.chat(model.Message{
+		Op:   enum.Chat,
+		Msg:  msg,
+		Area: enum.PublicScreen,
+	})
+}
+
+// Whisper 向 target 发送私聊消息，返回消息的 CorrelationID
+func (c *Client) Whisper(target, msg string) (string, error) {
+	return c.chat(model.Message{
+		Op:     enum.PrivateChat,
+		Msg:    msg,
+		Target: target,
+		Area:   enum.PrivateArea,
+	})
+}
+
+// SendToGroup 发送群聊消息，返回消息的 CorrelationID
+func (c *Client) SendToGroup(group, msg string) (string, error) {
+	return c.chat(model.Message{
+		Op:    enum.GroupChat,
+		Msg:   msg,
+		Group: group,
+		Area:  enum.GroupArea,
+	})
+}
+
+// CreateGroup 创建群组，创建者成为群主
+func (c *Client) CreateGroup(group string) error {
+	return c.call(model.Message{
+		Op:   enum.CreateGroup,
+		Msg:  group,
+		Area: enum.GroupArea,
+	})
+}
+
+// JoinGroup 加入群组
+func (c *Client) JoinGroup(group string) error {
+	return c.call(model.Message{
+		Op:    enum.JoinGroup,
+		Group: group,
+		Area:  enum.GroupArea,
+	})
+}
+
+// LeaveGroup 退出群组
+func (c *Client) LeaveGroup(group string) error {
+	return c.call(model.Message{
+		Op:    enum.LeaveGroup,
+		Group: group,
+		Area:  enum.GroupArea,
+	})
+}
+
+// InviteToGroup 邀请用户加入群组
+func (c *Client) InviteToGroup(group, user string) error {
+	return c.call(model.Message{
+		Op:     enum.InviteToGroup,
+		Group:  group,
+		Target: user,
+		Area:   enum.GroupArea,
+	})
+}
+
+// ListGroupMembers 查看群组成员，结果以 group_members 事件返回
+func (c *Client) ListGroupMembers(group string) error {
+	return c.call(model.Message{
+		Op:    enum.ListGroupMembers,
+		Group: group,
+		Area:  enum.GroupArea,
+	})
+}
+
+// ManageGroup 执行群组管理操作，op 为 PromoteAdmin、DemoteAdmin、KickMember、
+// BanMember、UnbanMember、TransferGroup 或 DissolveGroup，解散群组时 target 为空
+func (c *Client) ManageGroup(op enum.Operation, group, target string) error {
+	return c.call(model.Message{
+		Op:     op,
+		Group:  group,
+		Target: target,
+		Area:   enum.GroupArea,
+	})
+}
+
+// ListUsers 查看在线用户，结果以 user_list 事件返回
+func (c *Client) ListUsers() error {
+	return c.call(model.Message{
+		Op:   enum.ListUsers,
+		Area: enum.PublicScreen,
+	})
+}
+
+// ListGroups 查看群组列表，结果以 group_list 事件返回
+func (c *Client) ListGroups() error {
+	return c.call(model.Message{
+		Op:   enum.ListGroups,
+		Area: enum.PublicScreen,
+	})
+}
+
+// UpdateProfile 修改个人资料，结果以 profile 事件返回
+func (c *Client) UpdateProfile(user model.User) error {
+	userData, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+	return c.call(model.Message{
+		Op:   enum.UpdateUser,
+		Msg:  string(userData),
+		Area: enum.PublicScreen,
+	})
+}
+
+// MarkRead 将 peer 发来的私聊消息标记为已读，id 为看到的最后一条消息
+// 对方在线时会收到 read_receipt 事件，个人资料中关闭了已读回执时服务端忽略
+func (c *Client) MarkRead(peer string, id uint64) error {
+	return c.call(model.Message{
+		Op:     enum.MarkRead,
+		ID:     id,
+		Target: peer,
+		Area:   enum.PrivateArea,
+	})
+}
+
+// EditMessage 修改自己发送的消息，修改后的消息以 message_update 事件发给能看到原消息的用户
+func (c *Client) EditMessage(id uint64, msg string) error {
+	return c.call(model.Message{
+		Op:  enum.EditMessage,
+		ID:  id,
+		Msg: msg,
+	})
+}
+
+// DeleteMessage 删除自己发送的消息，群主和管理员还可以删除其管理的成员的群聊消息
+func (c *Client) DeleteMessage(id uint64) error {
+	return c.call(model.Message{
+		Op: enum.DeleteMessage,
+		ID: id,
+	})
+}
+
+// React 用表情回应消息，能看到原消息的在线用户会收到 reaction 事件
+func (c *Client) React(id uint64, emoji string) error {
+	return c.call(model.Message{
+		Op:  enum.React,
+		ID:  id,
+		Msg: emoji,
+	})
+}
+
+// Unreact 取消自己对消息的表情回应
+func (c *Client) Unreact(id uint64, emoji string) error {
+	return c.call(model.Message{
+		Op:  enum.Unreact,
+		ID:  id,
+		Msg: emoji,
+	})
+}
+
+// Typing 通知对方自己正在输入，area 为群聊时 name 为群组名称，为私聊时 name 为对方用户名
+// 服务端对同一会话限制转发频率，输入期间每隔 hub.TypingInterval 发送一次即可
+func (c *Client) Typing(area enum.Area, name string) error {
+	m := model.Message{
+		Op:   enum.Typing,
+		Area: area,
+	}
+	switch area {
+	case enum.GroupArea:
+		m.Group = name
+	case enum.PrivateArea:
+		m.Target = name
+	}
+	return c.call(m)
+}
+
+// FetchHistory 查询历史消息，结果以 history 事件返回
+// area 为群聊时 name 为群组名称，为私聊时 name 为对方用户名，公屏时忽略
+func (c *Client) FetchHistory(area enum.Area, name string, q model.HistoryQuery) error {
+	queryData, err := json.Marshal(q)
+	if err != nil {
+		return err
+	}
+	m := model.Message{
+		Op:   enum.FetchHistory,
+		Msg:  string(queryData),
+		Area: area,
+	}
+	switch area {
+	case enum.GroupArea:
+		m.Group = name
+	case enum.PrivateArea:
+		m.Target = name
+	}
+	return c.call(m)
+}
+
+// Logout 通知服务端下线，服务端随后会断开连接，之后不再自动重连
+func (c *Client) Logout() error {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+	return c.call(model.Message{
+		Op:   enum.Logout,
+		Area: enum.PublicScreen,
+	})
+}
+
+// Ping 向服务端发送心跳，服务端以 pong 事件回复，登录前也可以调用
+func (c *Client) Ping() error {
+	return c.Send(model.Message{Op: enum.Ping})
+}
+
+// Close 关闭连接并停止重连，接收协程随之退出
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	select {
+	case <-c.closing:
+	default:
+		close(c.closing)
+	}
+	return c.conn.Close()
+}
+
+// Send 发送一条原始消息，用于 SDK 没有封装的操作
+func (c *Client) Send(m model.Message) error {
+	// 设置时间戳
+	m.Timestamp = time.Now().Unix()
+
+	c.mu.Lock()
+	enc := c.enc
+	c.mu.Unlock()
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return enc.Encode(m)
+}
+
+// call 以登录的身份发送消息
+func (c *Client) call(m model.Message) error {
+	c.mu.Lock()
+	loggedIn, name := c.loggedIn, c.name
+	c.mu.Unlock()
+	if !loggedIn {
+		return ErrNotLoggedIn
+	}
+	m.Name = name
+	return c.Send(m)
+}
+
+// Reply 回复消息 replyTo，返回消息的 CorrelationID
+// area 为群聊时 name 为群组名称，为私聊时 name 为对方用户名，公屏时忽略，必须与原消息在同一个会话中
+func (c *Client) Reply(area enum.Area, name string, replyTo uint64, msg string) (string, error) {
+	m := model.Message{
+		Op:      enum.Chat,
+		Msg:     msg,
+		Area:    area,
+		ReplyTo: replyTo,
+	}
+	switch area {
+	case enum.GroupArea:
+		m.Op = enum.GroupChat
+		m.Group = name
+	case enum.PrivateArea:
+		m.Op = enum.PrivateChat
+		m.Target = name
+	default:
+		m.Area = enum.PublicScreen
+	}
+	return c.chat(m)
+}
+
+// chat 为聊天消息生成 CorrelationID 后发送，服务端返回的 Ack 事件带有相同的 CorrelationID
+func (c *Client) chat(m model.Message) (string, error) {
+	c.mu.Lock()
+	c.seq++
+	m.CorrelationID = strconv.FormatUint(c.seq, 10)
+	c.mu.Unlock()
+	return m.CorrelationID, c.call(m)
+}
+
+func (c *Client) isLoggedIn() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.loggedIn
+}
+
+// waitResult 等待接收协程转交的注册或登录结果
+func (c *Client) waitResult(ack, reject enum.EventKind) (model.Event, error) {
+	for {
+		select {
+		case event := <-c.results:
+			switch event.Kind {
+			case ack:
+				return event, nil
+			case reject:
+				return model.Event{}, &RejectedError{Event: event}
+			}
+		case <-c.done:
+			if c.err != nil {
+				return model.Event{}, c.err
+			}
+			return model.Event{}, io.EOF
+		}
+	}
+}
+
+// receive 持续读取服务端推送的事件，开启重连时断线后自动重连，最终断开时关闭事件通道
+func (c *Client) receive(dec *protocol.Decoder) {
+	defer close(c.done)
+	defer close(c.events)
+
+	for {
+		err := c.readLoop(dec)
+		if !c.shouldReconnect() {
+			c.err = err
+			return
+		}
+		dec, err = c.redial(err)
+		if err != nil {
+			c.err = err
+			return
+		}
+	}
+}
+
+// readLoop 读取一个连接上的事件，直到连接断开或超过 ReadTimeout 没有收到数据
+func (c *Client) readLoop(dec *protocol.Decoder) error {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+
+	for {
+		var event model.Event
+		if err := c.decode(conn, dec, &event); err != nil {
+			if err == protocol.ErrFrameTooLarge {
+				continue
+			}
+			return err
+		}
+		switch event.Kind {
+		case enum.LoginAckEvent:
+			// 先记录登录状态，之后分发的离线消息的回调中就可以发送消息
+			c.mu.Lock()
+			c.name = event.Name
+			c.loggedIn = true
+			c.mu.Unlock()
+			c.result(event)
+		case enum.LoginRejectEvent, enum.RegisterAckEvent, enum.RegisterRejectEvent:
+			c.result(event)
+		default:
+			c.handle(event)
+		}
+	}
+}
+
+// decode 读取下一个事件，超过 ReadTimeout 没有收到数据时返回超时错误
+func (c *Client) decode(conn net.Conn, dec *protocol.Decoder, event *model.Event) error {
+	conn.SetReadDeadline(time.Now().Add(ReadTimeout))
+	err := dec.Decode(event)
+	if err == nil || err == protocol.ErrFrameTooLarge {
+		c.mu.Lock()
+		c.lastRead = time.Now()
+		c.mu.Unlock()
+	}
+	return err
+}
+
+// keepalive 连接空闲超过 PingInterval 时发送 Ping，直到接收协程退出
+// 重连期间发送失败不影响重连
+func (c *Client) keepalive() {
+	ticker := time.NewTicker(PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.mu.Lock()
+			idle := time.Since(c.lastRead)
+			c.mu.Unlock()
+			if idle >= PingInterval {
+				c.Ping()
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// result 将注册或登录结果转交给等待中的调用
+// 没有等待中的请求时按普通事件分发，避免阻塞接收协程
+func (c *Client) result(event model.Event) {
+	select {
+	case c.results <- event:
+	default:
+		c.handle(event)
+	}
+}
+
+// handle 处理一个普通事件：自动回复心跳，记录最后收到的消息ID，其余交给调用方
+func (c *Client) handle(event model.Event) {
+	if event.Kind == enum.PingEvent {
+		c.pong()
+		return
+	}
+	if event.ID > 0 {
+		c.mu.Lock()
+		if event.ID > c.lastID {
+			c.lastID = event.ID
+		}
+		c.mu.Unlock()
+	}
+	c.dispatch(event)
+}
+
+// pong 回复服务端的心跳，失败时由接收协程在读取时发现连接断开
+func (c *Client) pong() {
+	c.Send(model.Message{Op: enum.Pong})
+}
+
+// dispatch 将事件交给回调，没有回调时写入事件通道
+func (c *Client) dispatch(event model.Event) {
+	c.mu.Lock()
+	handler := c.handler
+	c.mu.Unlock()
+
+	if handler != nil {
+		handler(event)
+		return
+	}
+	c.events <- event
+}
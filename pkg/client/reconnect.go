@@ -0,0 +1,133 @@
+package client
+
+import (
+	"go-chatroom/pkg/entity/model"
+	"go-chatroom/pkg/enum"
+	"go-chatroom/pkg/protocol"
+	"time"
+)
+
+// Reconnect 断线重连参数
+type Reconnect struct {
+	MinDelay    time.Duration // 第一次重连前的等待时间，之后每次失败翻倍
+	MaxDelay    time.Duration // 等待时间的上限
+	MaxAttempts int           // 连续失败的最大次数，0 表示不限制
+	// OnRetry 每次重连前调用，err 为断开或上一次重连失败的原因，可用于提示用户
+	OnRetry func(attempt int, delay time.Duration, err error)
+}
+
+// DefaultReconnect 默认重连参数：1 秒后开始重连，最长等待 30 秒，不限次数
+var DefaultReconnect = Reconnect{
+	MinDelay: time.Second,
+	MaxDelay: 30 * time.Second,
+}
+
+// EnableReconnect 开启断线自动重连，只对 Connect 创建的客户端生效
+// 重连后以相同的身份重新登录，并带上最后收到的消息ID，服务端会补发断线期间错过的消息
+// 服务端的 login_ack 会照常交给调用方，可以据此提示用户重连成功
+func (c *Client) EnableReconnect(r Reconnect) {
+	if r.MinDelay <= 0 {
+		r.MinDelay = DefaultReconnect.MinDelay
+	}
+	if r.MaxDelay < r.MinDelay {
+		r.MaxDelay = r.MinDelay
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reconnect = &r
+}
+
+// shouldReconnect 判断连接断开后是否需要重连：已登录、开启了重连且没有主动关闭
+func (c *Client) shouldReconnect() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.reconnect != nil && c.dial != nil && c.loggedIn && !c.closed
+}
+
+// redial 按指数退避重连，成功后返回新连接的解码器
+func (c *Client) redial(cause error) (*protocol.Decoder, error) {
+	c.mu.Lock()
+	r := *c.reconnect
+	c.mu.Unlock()
+
+	delay := r.MinDelay
+	for attempt := 1; r.MaxAttempts == 0 || attempt <= r.MaxAttempts; attempt++ {
+		if r.OnRetry != nil {
+			r.OnRetry(attempt, delay, cause)
+		}
+		select {
+		case <-time.After(delay):
+		case <-c.closing:
+			return nil, ErrClosed
+		}
+
+		dec, err := c.relogin()
+		if err == nil {
+			return dec, nil
+		}
+		if err == ErrClosed {
+			return nil, err
+		}
+		cause = err
+
+		delay *= 2
+		if delay > r.MaxDelay {
+			delay = r.MaxDelay
+		}
+	}
+	return nil, cause
+}
+
+// relogin 建立新连接并以原来的身份登录，同名会话尚未被服务端清理时会被拒绝，稍后再试即可
+func (c *Client) relogin() (*protocol.Decoder, error) {
+	conn, err := c.dial()
+	if err != nil {
+		return nil, err
+	}
+	dec := protocol.NewDecoder(conn, protocol.DefaultMaxFrameSize)
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		conn.Close()
+		return nil, ErrClosed
+	}
+	c.conn = conn
+	c.enc = protocol.NewEncoder(conn)
+	name, password, lastID := c.name, c.password, c.lastID
+	c.mu.Unlock()
+
+	err = c.Send(model.Message{
+		Name:   name,
+		Op:     enum.Login,
+		Msg:    password,
+		Area:   enum.PublicScreen,
+		LastID: lastID,
+	})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	for {
+		var event model.Event
+		if err := c.decode(conn, dec, &event); err != nil {
+			if err == protocol.ErrFrameTooLarge {
+				continue
+			}
+			conn.Close()
+			return nil, err
+		}
+		switch event.Kind {
+		case enum.LoginAckEvent:
+			c.dispatch(event)
+			return dec, nil
+		case enum.LoginRejectEvent:
+			conn.Close()
+			return nil, &RejectedError{Event: event}
+		default:
+			c.handle(event)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go-chatroom/pkg/protocol"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix 环境变量前缀，例如 CHATROOM_ADDR
+const EnvPrefix = "CHATROOM_"
+
+// TLS 传输层加密配置，服务端和客户端共用
+type TLS struct {
+	Enabled  bool   `json:"enabled"`   // 是否启用 TLS
+	CertFile string `json:"cert_file"` // 证书文件：服务端证书，或双向认证时的客户端证书
+	KeyFile  string `json:"key_file"`  // 证书私钥文件
+	// CAFile 服务端用于校验客户端证书，设置后要求客户端提供证书；客户端用于校验服务端证书，为空时使用系统根证书
+	CAFile             string `json:"ca_file"`
+	ServerName         string `json:"server_name"`          // 客户端校验的服务端名称，为空时取连接地址中的主机名
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"` // 客户端跳过服务端证书校验，仅用于本地调试
+}
+
+// Server 聊天服务端配置
+type Server struct {
+	Addr             string `json:"addr"`              // 监听地址
+	LogPath          string `json:"log_path"`          // 聊天日志文件路径
+	AccountsPath     string `json:"accounts_path"`     // 账号文件路径
+	HistoryPath      string `json:"history_path"`      // 消息历史文件路径
+	OfflinePath      string `json:"offline_path"`      // 离线消息文件路径
+	GroupsPath       string `json:"groups_path"`       // 群组文件路径，服务端退出时保存群组信息
+	MaxFrameSize     int    `json:"max_frame_size"`    // 单条消息最大字节数
+	SendQueue        int    `json:"send_queue"`        // 每个客户端的发送队列长度
+	WriteTimeout     int    `json:"write_timeout"`     // 单次写出的超时时间(秒)，0 表示不限制
+	SlowClient       string `json:"slow_client"`       // 发送队列已满时的处理策略：drop_oldest 或 disconnect
+	ShutdownTimeout  int    `json:"shutdown_timeout"`  // 退出时等待发送队列写完的时间(秒)，超时后直接断开
+	Heartbeat        int    `json:"heartbeat"`         // 向客户端发送心跳的间隔(秒)，0 表示不发送
+	HeartbeatTimeout int    `json:"heartbeat_timeout"` // 超过该时间(秒)未收到客户端任何消息即断开，需大于心跳间隔；0 或不发送心跳时不检测
+	HTTPAddr         string `json:"http_addr"`         // Web 页面和 WebSocket 监听地址，为空时不开启
+	WebDir           string `json:"web_dir"`           // 静态页面目录
+	TLS              TLS    `json:"tls"`               // TLS 配置，同时作用于 TCP 和 Web 服务
+}
+
+// Client 命令行客户端配置
+type Client struct {
+	ServerAddr string `json:"server_addr"` // 聊天服务端地址
+	Reconnect  bool   `json:"reconnect"`   // 断线后自动重连并补发断线期间的消息
+	Typing     bool   `json:"typing"`      // 显示对方的输入状态，并在输入私聊和群聊内容时通知对方
+	TLS        TLS    `json:"tls"`         // 连接服务端的 TLS 配置
+}
+
+// Config 全部配置，同一个配置文件可以同时供服务端和客户端使用
+type Config struct {
+	Server Server `json:"server"`
+	Client Client `json:"client"`
+}
+
+// Default 返回默认配置
+func Default() Config {
+	return Config{
+		Server: Server{
+			Addr:             "127.0.0.1:8000",
+			LogPath:          "chat.log",
+			AccountsPath:     "accounts.json",
+			HistoryPath:      "history.jsonl",
+			OfflinePath:      "offline.json",
+			GroupsPath:       "groups.json",
+			MaxFrameSize:     protocol.DefaultMaxFrameSize,
+			SendQueue:        1024,
+			WriteTimeout:     10,
+			SlowClient:       "disconnect",
+			ShutdownTimeout:  10,
+			Heartbeat:        30,
+			HeartbeatTimeout: 90,
+			HTTPAddr:         ":8080",
+			WebDir:           "../web",
+		},
+		Client: Client{
+			ServerAddr: "localhost:8000",
+			Reconnect:  true,
+		},
+	}
+}
+
+// Load 依次应用默认配置、配置文件和环境变量，path 为空时不读取配置文件
+func Load(path string) (Config, error) {
+	cfg := Default()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, err
+		}
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return cfg, fmt.Errorf("解析配置文件 %s 失败: %v", path, err)
+		}
+	}
+	if err := cfg.applyEnv(); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// Parse 加载配置并解析命令行参数，优先级为：命令行参数 > 环境变量 > 配置文件 > 默认值
+// 配置文件通过 -config 参数或 CHATROOM_CONFIG 环境变量指定，bind 用于注册各程序自己的参数
+func Parse(fs *flag.FlagSet, args []string, bind func(fs *flag.FlagSet, cfg *Config)) (Config, error) {
+	path := os.Getenv(EnvPrefix + "CONFIG")
+	if p, ok := lookupFlag(args, "config"); ok {
+		path = p
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		return cfg, err
+	}
+
+	// 参数的默认值取自已加载的配置，只有显式传入的参数才会覆盖
+	fs.String("config", path, "配置文件路径(JSON)")
+	bind(fs, &cfg)
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// ServerFlags 注册服务端参数
+func ServerFlags(fs *flag.FlagSet, cfg *Config) {
+	fs.StringVar(&cfg.Server.Addr, "addr", cfg.Server.Addr, "监听地址")
+	fs.StringVar(&cfg.Server.LogPath, "log", cfg.Server.LogPath, "聊天日志文件路径")
+	fs.StringVar(&cfg.Server.AccountsPath, "accounts", cfg.Server.AccountsPath, "账号文件路径")
+	fs.StringVar(&cfg.Server.HistoryPath, "history", cfg.Server.HistoryPath, "消息历史文件路径")
+	fs.StringVar(&cfg.Server.OfflinePath, "offline", cfg.Server.OfflinePath, "离线消息文件路径")
+	fs.StringVar(&cfg.Server.GroupsPath, "groups", cfg.Server.GroupsPath, "群组文件路径")
+	fs.IntVar(&cfg.Server.MaxFrameSize, "max-frame", cfg.Server.MaxFrameSize, "单条消息最大字节数")
+	fs.IntVar(&cfg.Server.SendQueue, "send-queue", cfg.Server.SendQueue, "每个客户端的发送队列长度")
+	fs.IntVar(&cfg.Server.WriteTimeout, "write-timeout", cfg.Server.WriteTimeout, "单次写出的超时时间(秒)，0 表示不限制")
+	fs.StringVar(&cfg.Server.SlowClient, "slow-client", cfg.Server.SlowClient, "发送队列已满时的处理策略：drop_oldest 或 disconnect")
+	fs.IntVar(&cfg.Server.ShutdownTimeout, "shutdown-timeout", cfg.Server.ShutdownTimeout, "退出时等待发送队列写完的时间(秒)")
+	fs.IntVar(&cfg.Server.Heartbeat, "heartbeat", cfg.Server.Heartbeat, "向客户端发送心跳的间隔(秒)，0 表示不发送")
+	fs.IntVar(&cfg.Server.HeartbeatTimeout, "heartbeat-timeout", cfg.Server.HeartbeatTimeout, "超过该时间(秒)未收到客户端消息即断开，需大于心跳间隔，0 表示不检测")
+	fs.StringVar(&cfg.Server.HTTPAddr, "http", cfg.Server.HTTPAddr, "Web 页面和 WebSocket 监听地址，为空时不开启")
+	fs.StringVar(&cfg.Server.WebDir, "web", cfg.Server.WebDir, "静态页面目录")
+	fs.BoolVar(&cfg.Server.TLS.Enabled, "tls", cfg.Server.TLS.Enabled, "启用 TLS")
+	fs.StringVar(&cfg.Server.TLS.CertFile, "tls-cert", cfg.Server.TLS.CertFile, "服务端证书文件")
+	fs.StringVar(&cfg.Server.TLS.KeyFile, "tls-key", cfg.Server.TLS.KeyFile, "服务端证书私钥文件")
+	fs.StringVar(&cfg.Server.TLS.CAFile, "tls-client-ca", cfg.Server.TLS.CAFile, "校验客户端证书的 CA 文件，设置后要求客户端证书")
+}
+
+// ClientFlags 注册命令行客户端参数
+func ClientFlags(fs *flag.FlagSet, cfg *Config) {
+	fs.StringVar(&cfg.Client.ServerAddr, "server", cfg.Client.ServerAddr, "聊天服务端地址")
+	fs.BoolVar(&cfg.Client.Reconnect, "reconnect", cfg.Client.Reconnect, "断线后自动重连")
+	fs.BoolVar(&cfg.Client.Typing, "typing", cfg.Client.Typing, "显示和发送输入状态")
+	fs.BoolVar(&cfg.Client.TLS.Enabled, "tls", cfg.Client.TLS.Enabled, "使用 TLS 连接服务端")
+	fs.StringVar(&cfg.Client.TLS.CAFile, "tls-ca", cfg.Client.TLS.CAFile, "校验服务端证书的 CA 文件")
+	fs.StringVar(&cfg.Client.TLS.CertFile, "tls-cert", cfg.Client.TLS.CertFile, "客户端证书文件（服务端要求双向认证时使用）")
+	fs.StringVar(&cfg.Client.TLS.KeyFile, "tls-key", cfg.Client.TLS.KeyFile, "客户端证书私钥文件")
+	fs.StringVar(&cfg.Client.TLS.ServerName, "tls-server-name", cfg.Client.TLS.ServerName, "校验的服务端名称")
+	fs.BoolVar(&cfg.Client.TLS.InsecureSkipVerify, "tls-insecure", cfg.Client.TLS.InsecureSkipVerify, "跳过服务端证书校验（仅用于调试）")
+}
+
+// applyEnv 使用环境变量覆盖配置
+func (c *Config) applyEnv() error {
+	envString("ADDR", &c.Server.Addr)
+	envString("LOG", &c.Server.LogPath)
+	envString("ACCOUNTS", &c.Server.AccountsPath)
+	envString("HISTORY", &c.Server.HistoryPath)
+	envString("OFFLINE", &c.Server.OfflinePath)
+	envString("GROUPS", &c.Server.GroupsPath)
+	if err := envInt("MAX_FRAME", &c.Server.MaxFrameSize); err != nil {
+		return err
+	}
+	if err := envInt("SEND_QUEUE", &c.Server.SendQueue); err != nil {
+		return err
+	}
+	if err := envInt("WRITE_TIMEOUT", &c.Server.WriteTimeout); err != nil {
+		return err
+	}
+	envString("SLOW_CLIENT", &c.Server.SlowClient)
+	if err := envInt("SHUTDOWN_TIMEOUT", &c.Server.ShutdownTimeout); err != nil {
+		return err
+	}
+	if err := envInt("HEARTBEAT", &c.Server.Heartbeat); err != nil {
+		return err
+	}
+	if err := envInt("HEARTBEAT_TIMEOUT", &c.Server.HeartbeatTimeout); err != nil {
+		return err
+	}
+	envString("TLS_CERT", &c.Server.TLS.CertFile)
+	envString("TLS_KEY", &c.Server.TLS.KeyFile)
+	envString("TLS_CLIENT_CA", &c.Server.TLS.CAFile)
+	envString("HTTP_ADDR", &c.Server.HTTPAddr)
+	envString("WEB_DIR", &c.Server.WebDir)
+
+	// 同一个开关同时作用于服务端和客户端
+	for _, t := range []*TLS{&c.Server.TLS, &c.Client.TLS} {
+		if err := envBool("TLS", &t.Enabled); err != nil {
+			return err
+		}
+	}
+	envString("TLS_CA", &c.Client.TLS.CAFile)
+
+	envString("SERVER", &c.Client.ServerAddr)
+	if err := envBool("RECONNECT", &c.Client.Reconnect); err != nil {
+		return err
+	}
+	if err := envBool("TYPING", &c.Client.Typing); err != nil {
+		return err
+	}
+	return nil
+}
+
+func envString(key string, dst *string) {
+	if v, ok := os.LookupEnv(EnvPrefix + key); ok {
+		*dst = v
+	}
+}
+
+func envInt(key string, dst *int) error {
+	v, ok := os.LookupEnv(EnvPrefix + key)
+	if !ok {
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("环境变量 %s%s 不是有效的整数: %v", EnvPrefix, key, err)
+	}
+	*dst = n
+	return nil
+}
+
+func envBool(key string, dst *bool) error {
+	v, ok := os.LookupEnv(EnvPrefix + key)
+	if !ok {
+		return nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return fmt.Errorf("环境变量 %s%s 不是有效的布尔值: %v", EnvPrefix, key, err)
+	}
+	*dst = b
+	return nil
+}
+
+// lookupFlag 在正式解析之前从参数中找出指定参数的值，支持 -name value 与 -name=value 两种写法
+func lookupFlag(args []string, name string) (string, bool) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return "", false
+		}
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		arg = strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		if arg == name && i+1 < len(args) {
+			return args[i+1], true
+		}
+		if strings.HasPrefix(arg, name+"=") {
+			return arg[len(name)+1:], true
+		}
+	}
+	return "", false
+}
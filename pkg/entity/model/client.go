@@ -2,9 +2,18 @@ package model
 
 import "net"
 
+// Session 客户端连接的抽象，服务端只通过它向客户端发送事件
+// TCP、TLS、WebSocket 以及测试用的内存管道等传输方式都实现该接口（见 pkg/transport）
+type Session interface {
+	ID() string           // 连接的唯一标识
+	Send(e Event) error   // 向客户端发送一条事件
+	Close() error         // 关闭连接
+	RemoteAddr() net.Addr // 客户端地址
+}
+
 type Client struct {
-	Conn   net.Conn      `json:"-"`       // 连接信息 - skip JSON serialization
-	Name   string        `json:"name"`    // 别名
-	IsQuit bool          `json:"is_quit"` // 是否退出
-	User   `json:"user"` // Embedded user info
+	Session Session       `json:"-"`       // 连接会话 - skip JSON serialization
+	Name    string        `json:"name"`    // 别名
+	IsQuit  bool          `json:"is_quit"` // 是否退出
+	User    `json:"user"` // Embedded user info
 }
@@ -0,0 +1,53 @@
+package model
+
+import (
+	"go-chatroom/pkg/enum"
+	"time"
+)
+
+// Quote 回复时引用的原消息，保存的是回复时的内容
+type Quote struct {
+	Name string `json:"name"` // 原消息的发送者
+	Msg  string `json:"msg"`  // 原消息的内容，过长时被截断
+}
+
+// Event 服务端推送给客户端的事件
+type Event struct {
+	ID        uint64                    `json:"id,omitempty"`        // 消息ID，仅聊天消息有
+	Kind      enum.EventKind            `json:"kind"`                // 事件类型
+	Name      string                    `json:"name"`                // 发送者
+	Msg       string                    `json:"msg"`                 // 信息内容
+	Target    string                    `json:"target"`              // 目标用户(私聊时使用)
+	Group     string                    `json:"group"`               // 群组名称(群聊时使用)
+	Timestamp int64                     `json:"timestamp"`           // 服务端时间戳
+	Area      enum.Area                 `json:"area"`                // 聊天区域类型
+	List      []string                  `json:"list,omitempty"`      // 用户或群组列表
+	User      *User                     `json:"user,omitempty"`      // 用户资料
+	Roles     map[string]enum.GroupRole `json:"roles,omitempty"`     // 群成员角色
+	History   []Event                   `json:"history,omitempty"`   // 历史消息
+	Pending   int                       `json:"pending,omitempty"`   // 登录时待投递的离线消息数
+	Truncated bool                      `json:"truncated,omitempty"` // 断线期间错过的消息超过补发上限，只补发了最近的部分
+
+	CorrelationID string              `json:"correlation_id,omitempty"` // 对应消息的 CorrelationID，仅 Ack 事件有
+	Status        enum.DeliveryStatus `json:"status,omitempty"`         // 投递状态，仅 Ack 事件有
+	Delivered     int                 `json:"delivered,omitempty"`      // 已送达的在线接收者数，仅 Ack 事件有
+
+	ReplyTo uint64 `json:"reply_to,omitempty"` // 回复的消息ID
+	Quote   *Quote `json:"quote,omitempty"`    // 回复时引用的原消息
+
+	Reactions map[string][]string `json:"reactions,omitempty"` // 表情回应，键为表情，值为回应的用户
+
+	EditedAt  int64  `json:"edited_at,omitempty"`  // 最后一次修改或删除的时间
+	Deleted   bool   `json:"deleted,omitempty"`    // 消息已被删除，Msg 为空
+	DeletedBy string `json:"deleted_by,omitempty"` // 删除消息的用户
+}
+
+// NewNotice 构造一条服务端发出的提示事件，时间戳为当前时间
+func NewNotice(kind enum.EventKind, area enum.Area, msg string) Event {
+	return Event{
+		Kind:      kind,
+		Msg:       msg,
+		Timestamp: time.Now().Unix(),
+		Area:      area,
+	}
+}
@@ -0,0 +1,100 @@
+package model
+
+import (
+	"go-chatroom/pkg/enum"
+	"sort"
+)
+
+// Group 群组信息
+type Group struct {
+	Name      string                    `json:"name"`       // 群组名称
+	Owner     string                    `json:"owner"`      // 群主
+	Members   map[string]enum.GroupRole `json:"members"`    // 成员及其角色
+	Banned    map[string]bool           `json:"banned"`     // 被封禁、不能再加入的用户
+	CreatedBy string                    `json:"created_by"` // 创建者
+	CreatedAt int64                     `json:"created_at"` // 创建时间
+	Since     uint64                    `json:"since"`      // 创建时最后一条消息的 ID，之前同名群组的聊天记录不属于本群
+}
+
+// NewGroup 创建群组，创建者成为群主
+func NewGroup(name, creator string, createdAt int64) *Group {
+	return &Group{
+		Name:      name,
+		Owner:     creator,
+		Members:   map[string]enum.GroupRole{creator: enum.GroupOwner},
+		Banned:    make(map[string]bool),
+		CreatedBy: creator,
+		CreatedAt: createdAt,
+	}
+}
+
+// Contains 判断历史消息是否为本群的群聊消息，解散后重建的同名群组看不到之前的记录
+func (g *Group) Contains(e Event) bool {
+	return e.Area == enum.GroupArea && e.Group == g.Name && e.ID > g.Since
+}
+
+// IsMember 判断用户是否为群组成员
+func (g *Group) IsMember(name string) bool {
+	_, exists := g.Members[name]
+	return exists
+}
+
+// IsAdmin 判断用户是否拥有管理权限（群主或管理员）
+func (g *Group) IsAdmin(name string) bool {
+	role := g.Members[name]
+	return role == enum.GroupOwner || role == enum.GroupAdmin
+}
+
+// IsBanned 判断用户是否被封禁
+func (g *Group) IsBanned(name string) bool {
+	return g.Banned[name]
+}
+
+// AddMember 以普通成员身份加入群组
+func (g *Group) AddMember(name string) {
+	g.Members[name] = enum.GroupMember
+}
+
+// RemoveMember 将用户移出群组
+func (g *Group) RemoveMember(name string) {
+	delete(g.Members, name)
+}
+
+// SetRole 设置成员角色，设为群主时原群主降为管理员
+func (g *Group) SetRole(name string, role enum.GroupRole) {
+	if role == enum.GroupOwner {
+		g.Members[g.Owner] = enum.GroupAdmin
+		g.Owner = name
+	}
+	g.Members[name] = role
+}
+
+// Ban 封禁用户并将其移出群组
+func (g *Group) Ban(name string) {
+	g.RemoveMember(name)
+	g.Banned[name] = true
+}
+
+// Unban 解除封禁
+func (g *Group) Unban(name string) {
+	delete(g.Banned, name)
+}
+
+// MemberNames 按名称排序的成员列表
+func (g *Group) MemberNames() []string {
+	names := make([]string, 0, len(g.Members))
+	for name := range g.Members {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Roles 成员角色的副本
+func (g *Group) Roles() map[string]enum.GroupRole {
+	roles := make(map[string]enum.GroupRole, len(g.Members))
+	for name, role := range g.Members {
+		roles[name] = role
+	}
+	return roles
+}
@@ -0,0 +1,8 @@
+package model
+
+// HistoryQuery 查询历史消息的参数，以 JSON 形式放在 Message.Msg 中
+type HistoryQuery struct {
+	Limit    int    `json:"limit"`     // 最多返回的条数
+	BeforeID uint64 `json:"before_id"` // 只返回 ID 小于该值的消息，用于翻页
+	Before   int64  `json:"before"`    // 只返回时间戳早于该值的消息
+}
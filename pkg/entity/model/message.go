@@ -10,4 +10,12 @@ type Message struct {
 	Group     string         `json:"group"`     // 群组名称(群聊时使用)
 	Timestamp int64          `json:"timestamp"` // 时间戳
 	Area      enum.Area      `json:"area"`      // 聊天区域类型
+	// LastID 客户端已收到的最后一条消息ID，断线重连后登录时携带，服务端据此补发断线期间的消息
+	LastID uint64 `json:"last_id,omitempty"`
+	// ID 操作针对的消息ID，标记已读、修改和删除消息时使用
+	ID uint64 `json:"id,omitempty"`
+	// ReplyTo 回复的消息ID，必须与本条消息在同一个公屏、群聊或私聊会话中
+	ReplyTo uint64 `json:"reply_to,omitempty"`
+	// CorrelationID 客户端为聊天消息生成的标识，服务端在 Ack 事件中原样返回，用于对应投递结果
+	CorrelationID string `json:"correlation_id,omitempty"`
 }
@@ -3,4 +3,6 @@ package model
 type User struct {
 	Age string `json:"age"` //年龄
 	Sex string `json:"sex"` //性别
+
+	HideReadReceipts bool `json:"hide_read_receipts,omitempty"` // 不向私聊对象发送已读回执
 }
@@ -0,0 +1,43 @@
+package enum
+
+type EventKind string
+
+const (
+	ChatEvent        EventKind = "chat"         // 聊天消息
+	LoginEvent       EventKind = "login"        // 用户上线通知
+	LogoutEvent      EventKind = "logout"       // 用户下线通知
+	NoticeEvent      EventKind = "notice"       // 服务端提示信息
+	ErrorEvent       EventKind = "error"        // 服务端错误信息
+	UserListEvent    EventKind = "user_list"    // 在线用户列表
+	GroupListEvent   EventKind = "group_list"   // 群组列表
+	ProfileEvent     EventKind = "profile"      // 用户资料
+	LoginAckEvent    EventKind = "login_ack"    // 登录成功，仅发给登录者本人
+	LoginRejectEvent EventKind = "login_reject" // 登录失败，Msg 为失败原因
+
+	RegisterAckEvent    EventKind = "register_ack"    // 注册成功
+	RegisterRejectEvent EventKind = "register_reject" // 注册失败，Msg 为失败原因
+
+	GroupJoinEvent    EventKind = "group_join"    // 成员加入群组，Name 为操作者，Target 为新成员
+	GroupLeaveEvent   EventKind = "group_leave"   // 成员离开群组，Name 为操作者，Target 为离开的成员
+	GroupMembersEvent EventKind = "group_members" // 群组成员列表
+
+	GroupRoleEvent     EventKind = "group_role"     // 成员角色变更，Target 为成员，Msg 为新角色
+	GroupBanEvent      EventKind = "group_ban"      // 用户被封禁并移出群组
+	GroupUnbanEvent    EventKind = "group_unban"    // 用户被解除封禁
+	GroupDissolveEvent EventKind = "group_dissolve" // 群组被解散
+
+	HistoryEvent EventKind = "history" // 历史消息查询结果
+
+	ShutdownEvent EventKind = "shutdown" // 服务端即将关闭，Msg 为提示信息
+
+	PingEvent EventKind = "ping" // 服务端心跳探测，客户端需回复 Pong 操作
+	PongEvent EventKind = "pong" // 回复客户端的 Ping 操作
+
+	AckEvent EventKind = "ack" // 聊天消息的投递结果，仅发给携带了 CorrelationID 的发送者
+
+	ReadReceiptEvent EventKind = "read_receipt" // 私聊已读回执，Name 为读者，ID 及之前发给读者的私聊消息都已读
+	TypingEvent      EventKind = "typing"       // Name 正在私聊或群聊中输入，不会保存
+
+	MessageUpdateEvent EventKind = "message_update" // 消息被修改或删除，内容为修改后的完整消息，按 ID 替换
+	ReactionEvent      EventKind = "reaction"       // 消息的表情回应变化，Name 为操作者，Reactions 为该消息当前的全部回应
+)
@@ -14,6 +14,34 @@ const (
 	CreateGroup // 创建群组
 	ListGroups  // 列出群组
 	ListUsers   // 列出在线用户
+	Register    // 注册账号
+
+	JoinGroup        // 加入群组
+	LeaveGroup       // 退出群组
+	InviteToGroup    // 邀请用户加入群组
+	ListGroupMembers // 列出群组成员
+
+	PromoteAdmin  // 设为群管理员（群主）
+	DemoteAdmin   // 取消群管理员（群主）
+	KickMember    // 将成员移出群组（群主、管理员）
+	BanMember     // 封禁用户，禁止再次加入（群主、管理员）
+	UnbanMember   // 解除封禁（群主、管理员）
+	TransferGroup // 转让群主（群主）
+	DissolveGroup // 解散群组（群主）
+
+	FetchHistory // 查询历史消息
+
+	Ping // 心跳探测，服务端回复 pong 事件
+	Pong // 回复服务端的 ping 事件
+
+	MarkRead // 标记私聊消息已读，ID 为已读的最后一条消息，Target 为其发送者
+	Typing   // 正在输入，私聊时 Target 为对方，群聊时 Group 为群组
+
+	EditMessage   // 修改消息，ID 为要修改的消息，Msg 为新内容（发送者本人）
+	DeleteMessage // 删除消息，ID 为要删除的消息（发送者本人、群主、管理员）
+
+	React   // 用表情回应消息，ID 为消息，Msg 为表情
+	Unreact // 取消表情回应
 )
 
 func MsgToOperation(msg string) (op Operation) {
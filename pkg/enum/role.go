@@ -0,0 +1,23 @@
+package enum
+
+type GroupRole string
+
+const (
+	GroupOwner  GroupRole = "owner"  // 群主
+	GroupAdmin  GroupRole = "admin"  // 管理员
+	GroupMember GroupRole = "member" // 普通成员
+)
+
+var roleMap = map[GroupRole]string{
+	GroupOwner:  "群主",
+	GroupAdmin:  "管理员",
+	GroupMember: "成员",
+}
+
+// RoleName 角色的中文名称
+func (r GroupRole) RoleName() string {
+	if name, exist := roleMap[r]; exist {
+		return name
+	}
+	return roleMap[GroupMember]
+}
@@ -0,0 +1,9 @@
+package enum
+
+type DeliveryStatus string
+
+const (
+	StatusSent      DeliveryStatus = "sent"      // 已发送，接收者都不在线，上线后送达
+	StatusDelivered DeliveryStatus = "delivered" // 已送达在线接收者
+	StatusFailed    DeliveryStatus = "failed"    // 发送失败
+)
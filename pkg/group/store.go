@@ -0,0 +1,129 @@
+package group
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-chatroom/pkg/entity/model"
+	"go-chatroom/pkg/enum"
+	"go-chatroom/pkg/fsutil"
+	"os"
+	"sync"
+)
+
+// Load 从 path 加载群组信息，文件不存在时返回空的群组表
+func Load(path string) (map[string]*model.Group, error) {
+	groups := make(map[string]*model.Group)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return groups, nil
+		}
+		return nil, err
+	}
+	if len(data) == 0 {
+		return groups, nil
+	}
+	if err := json.Unmarshal(data, &groups); err != nil {
+		return nil, err
+	}
+	for _, g := range groups {
+		// 旧文件或手工编辑的文件中可能缺少这些字段
+		if g.Members == nil {
+			g.Members = make(map[string]enum.GroupRole)
+		}
+		if g.Banned == nil {
+			g.Banned = make(map[string]bool)
+		}
+	}
+	return groups, nil
+}
+
+// Writer 在后台协程中保存群组信息，调用方不必等待写文件
+// 连续多次修改只写入最新的一份
+type Writer struct {
+	path string
+
+	mu   sync.Mutex
+	data []byte // 尚未写入的最新内容，为空表示没有待写入的修改
+
+	wake    chan struct{} // 有新内容时通知后台协程
+	quit    chan struct{} // 停止信号
+	stopped chan struct{} // 后台协程退出后关闭
+}
+
+// NewWriter 创建写入 path 的 Writer 并启动后台协程，不再使用时需要调用 Close
+func NewWriter(path string) *Writer {
+	w := &Writer{
+		path:    path,
+		wake:    make(chan struct{}, 1),
+		quit:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Save 序列化当前的群组信息并交给后台协程写入
+// 序列化在调用方的协程中完成，因此写入的是调用时的状态
+func (w *Writer) Save(groups map[string]*model.Group) error {
+	data, err := json.MarshalIndent(groups, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	w.data = data
+	w.mu.Unlock()
+
+	select {
+	case w.wake <- struct{}{}:
+	default:
+		// 后台协程已经有待处理的通知
+	}
+	return nil
+}
+
+// Close 写入尚未保存的修改后停止后台协程
+func (w *Writer) Close() {
+	select {
+	case <-w.quit:
+	default:
+		close(w.quit)
+	}
+	<-w.stopped
+}
+
+func (w *Writer) run() {
+	defer close(w.stopped)
+
+	for {
+		select {
+		case <-w.wake:
+			w.flush()
+		case <-w.quit:
+			w.flush()
+			return
+		}
+	}
+}
+
+// flush 写入最新的内容，失败时保留该内容，在下次修改或关闭时重试
+func (w *Writer) flush() {
+	w.mu.Lock()
+	data := w.data
+	w.data = nil
+	w.mu.Unlock()
+	if data == nil {
+		return
+	}
+
+	if err := fsutil.WriteFileAtomic(w.path, data); err != nil {
+		fmt.Printf("保存群组信息失败: %v\n", err)
+		w.mu.Lock()
+		if w.data == nil {
+			w.data = data
+		}
+		w.mu.Unlock()
+	}
+}
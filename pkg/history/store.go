@@ -0,0 +1,267 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-chatroom/pkg/entity/model"
+	"go-chatroom/pkg/enum"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+// ErrNotFound 要修改的消息不存在
+var ErrNotFound = errors.New("history: message not found")
+
+const (
+	DefaultLimit = 20   // 默认每页条数
+	MaxLimit     = 200  // 每页最大条数
+	MaxResume    = 1000 // 断线重连时最多补发的条数
+)
+
+// Query 历史消息查询条件
+type Query struct {
+	Area     enum.Area // 聊天区域
+	Group    string    // 群组名称（群聊时使用）
+	User     string    // 查询者（私聊时使用）
+	Peer     string    // 私聊对象（私聊时使用）
+	Limit    int       // 最多返回的条数
+	BeforeID uint64    // 只返回 ID 小于该值的消息，0 表示不限制
+	AfterID  uint64    // 只返回 ID 大于该值的消息，0 表示不限制
+	Before   int64     // 只返回时间戳早于该值的消息，0 表示不限制
+}
+
+// Store 以追加写 JSON 行文件保存的消息历史，启动时全部加载到内存中
+type Store struct {
+	mu      sync.RWMutex
+	file    *os.File
+	records []model.Event
+	nextID  uint64
+}
+
+// Open 打开（或创建）历史文件并加载已有记录
+func Open(path string) (*Store, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store{file: file, nextID: 1}
+	if err := s.load(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// load 逐行读取历史文件，无法解析的行（例如写到一半时进程退出）会被跳过
+// 修改和删除以相同 ID 追加新的一行，后出现的行覆盖之前的记录
+func (s *Store) load() error {
+	var offset int64 // 已读取的完整行的长度
+	reader := bufio.NewReader(s.file)
+	for lineNo := 1; ; lineNo++ {
+		line, err := reader.ReadBytes('\n')
+		parsed := false
+		if len(bytes.TrimSpace(line)) > 0 {
+			var record model.Event
+			if jsonErr := json.Unmarshal(line, &record); jsonErr != nil {
+				fmt.Printf("跳过无法解析的历史记录 第%d行: %v\n", lineNo, jsonErr)
+			} else {
+				parsed = true
+				s.put(record)
+				if record.ID >= s.nextID {
+					s.nextID = record.ID + 1
+				}
+			}
+		}
+		if err == io.EOF {
+			return s.repairTail(offset, line, parsed)
+		}
+		if err != nil {
+			return err
+		}
+		offset += int64(len(line))
+	}
+}
+
+// repairTail 处理没有以换行结尾的最后一行，否则之后追加的记录会接在它后面，重新加载时一起丢失
+// 该行完整时补上换行，写到一半时截断
+func (s *Store) repairTail(offset int64, tail []byte, parsed bool) error {
+	if len(tail) == 0 {
+		return nil
+	}
+	if parsed {
+		_, err := s.file.Write([]byte{'\n'})
+		return err
+	}
+	return s.file.Truncate(offset)
+}
+
+// Append 为消息分配 ID 并写入历史，返回带 ID 的消息
+// 写入失败时 ID 同样被占用，返回的消息仍带有唯一的 ID
+func (s *Store) Append(e model.Event) (model.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e.ID = s.nextID
+	s.nextID++
+	data, err := json.Marshal(e)
+	if err != nil {
+		return e, err
+	}
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return e, err
+	}
+	s.records = append(s.records, e)
+	return e, nil
+}
+
+// Update 保存修改或删除后的消息，以相同 ID 追加一行，之后的查询返回修改后的内容
+func (s *Store) Update(e model.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.index(e.ID)
+	if !ok {
+		return ErrNotFound
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	s.records[i] = e
+	return nil
+}
+
+// LastID 返回最后分配的消息 ID，还没有消息时为 0
+func (s *Store) LastID() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.nextID - 1
+}
+
+// Get 返回指定 ID 的消息，修改过的消息返回最新的内容
+func (s *Store) Get(id uint64) (model.Event, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if i, ok := s.index(id); ok {
+		return s.records[i], true
+	}
+	return model.Event{}, false
+}
+
+// index 查找指定 ID 的记录，没有时返回应当插入的位置
+// 每个 ID 只保留一条记录，且按 ID 递增排列
+func (s *Store) index(id uint64) (int, bool) {
+	i := sort.Search(len(s.records), func(i int) bool {
+		return s.records[i].ID >= id
+	})
+	return i, i < len(s.records) && s.records[i].ID == id
+}
+
+// put 按 ID 插入记录，已有相同 ID 的记录时覆盖
+func (s *Store) put(e model.Event) {
+	i, ok := s.index(e.ID)
+	if ok {
+		s.records[i] = e
+		return
+	}
+	s.records = append(s.records, model.Event{})
+	copy(s.records[i+1:], s.records[i:])
+	s.records[i] = e
+}
+
+// Query 按条件返回最近的若干条消息，结果按 ID 从小到大排列
+func (s *Store) Query(q Query) []model.Event {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	if limit > MaxLimit {
+		limit = MaxLimit
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []model.Event
+	for i := len(s.records) - 1; i >= 0 && len(result) < limit; i-- {
+		record := s.records[i]
+		if record.ID <= q.AfterID {
+			break
+		}
+		if q.BeforeID != 0 && record.ID >= q.BeforeID {
+			continue
+		}
+		if q.Before != 0 && record.Timestamp >= q.Before {
+			continue
+		}
+		if !q.match(record) {
+			continue
+		}
+		result = append(result, record)
+	}
+
+	// 倒序收集，翻转为时间顺序
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result
+}
+
+// Since 返回 ID 大于 afterID 且 visible 返回 true 的消息，按 ID 从小到大排列
+// 超过 MaxResume 条时只返回最近的 MaxResume 条，truncated 为 true，更早的消息需要分页查询
+func (s *Store) Since(afterID uint64, visible func(e model.Event) bool) (result []model.Event, truncated bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// 记录按 ID 递增排列，从后往前收集直到遇到不需要补发的消息
+	for i := len(s.records) - 1; i >= 0 && s.records[i].ID > afterID; i-- {
+		if !visible(s.records[i]) {
+			continue
+		}
+		if len(result) >= MaxResume {
+			truncated = true
+			break
+		}
+		result = append(result, s.records[i])
+	}
+
+	// 倒序收集，翻转为时间顺序
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result, truncated
+}
+
+// match 判断消息是否属于查询的会话
+func (q Query) match(e model.Event) bool {
+	if e.Area != q.Area {
+		return false
+	}
+	switch q.Area {
+	case enum.GroupArea:
+		return e.Group == q.Group
+	case enum.PrivateArea:
+		return (e.Name == q.User && e.Target == q.Peer) || (e.Name == q.Peer && e.Target == q.User)
+	default:
+		return true
+	}
+}
+
+// Close 关闭历史文件
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.file.Close()
+}
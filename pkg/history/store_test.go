@@ -0,0 +1,114 @@
+package history
+
+import (
+	"go-chatroom/pkg/entity/model"
+	"go-chatroom/pkg/enum"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func openStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open(filepath.Join(t.TempDir(), "history.jsonl"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func all(model.Event) bool { return true }
+
+func TestSince(t *testing.T) {
+	s := openStore(t)
+	for i := 0; i < 5; i++ {
+		area := enum.PublicScreen
+		if i%2 == 1 {
+			area = enum.PrivateArea
+		}
+		s.Append(model.Event{Area: area})
+	}
+
+	events, truncated := s.Since(1, func(e model.Event) bool { return e.Area == enum.PublicScreen })
+	if truncated || len(events) != 2 || events[0].ID != 3 || events[1].ID != 5 {
+		t.Fatalf("Since = %v, %v, want [3 5]", events, truncated)
+	}
+}
+
+func TestSinceTruncated(t *testing.T) {
+	s := openStore(t)
+	for i := 0; i < MaxResume+10; i++ {
+		s.Append(model.Event{Area: enum.PublicScreen})
+	}
+
+	// 超过上限时补发最近的消息，更早的消息由客户端分页查询
+	events, truncated := s.Since(0, all)
+	if !truncated {
+		t.Fatal("truncated = false, want true")
+	}
+	if len(events) != MaxResume || events[0].ID != 11 || events[len(events)-1].ID != MaxResume+10 {
+		t.Fatalf("got %d events from %d to %d", len(events), events[0].ID, events[len(events)-1].ID)
+	}
+
+	if events, truncated := s.Since(10, all); truncated || len(events) != MaxResume {
+		t.Fatalf("Since(10) = %d events, truncated %v, want exactly MaxResume", len(events), truncated)
+	}
+}
+
+func TestPartialLastLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	// 第二条记录写到一半时进程退出
+	data := `{"id":1,"kind":"chat","msg":"ok"}` + "\n" + `{"id":2,"kind":"ch`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	e, err := s.Append(model.Event{Msg: "next"})
+	if err != nil || e.ID != 2 {
+		t.Fatalf("Append = %d, %v, want id 2", e.ID, err)
+	}
+	s.Close()
+
+	s, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+	if got, ok := s.Get(2); !ok || got.Msg != "next" {
+		t.Fatalf("Get(2) = %+v, %v, want the appended record", got, ok)
+	}
+	if s.LastID() != 2 {
+		t.Fatalf("LastID = %d, want 2", s.LastID())
+	}
+}
+
+func TestLastLineWithoutNewline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	// 记录完整，只缺少结尾的换行
+	if err := os.WriteFile(path, []byte(`{"id":1,"kind":"chat","msg":"ok"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	s.Append(model.Event{Msg: "next"})
+	s.Close()
+
+	s, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+	for id := uint64(1); id <= 2; id++ {
+		if _, ok := s.Get(id); !ok {
+			t.Fatalf("Get(%d) missed", id)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package hub
+
+import (
+	"go-chatroom/pkg/entity/model"
+	"go-chatroom/pkg/enum"
+)
+
+// ack 向发送者返回聊天消息的投递结果，消息没有携带 CorrelationID 时不返回
+// delivered 为已送达的在线接收者数，为 0 时表示消息已保存，接收者上线后送达
+func (h *Hub) ack(m model.Message, e model.Event, delivered int) {
+	if m.CorrelationID == "" {
+		return
+	}
+	event := ackEvent(m, e.Area)
+	event.ID = e.ID
+	event.Status = enum.StatusSent
+	if delivered > 0 {
+		event.Status = enum.StatusDelivered
+		event.Delivered = delivered
+	}
+	h.sendTo(m.Name, event)
+}
+
+// reject 通知发送者聊天消息发送失败，携带了 CorrelationID 时返回 failed 状态的 Ack 事件，
+// Msg 为失败原因，否则返回错误事件
+func (h *Hub) reject(m model.Message, area enum.Area, reason string) {
+	if m.CorrelationID == "" {
+		h.sendTo(m.Name, model.NewNotice(enum.ErrorEvent, area, reason))
+		return
+	}
+	event := ackEvent(m, area)
+	event.Msg = reason
+	event.Status = enum.StatusFailed
+	h.sendTo(m.Name, event)
+}
+
+func ackEvent(m model.Message, area enum.Area) model.Event {
+	event := model.NewNotice(enum.AckEvent, area, "")
+	event.Name = m.Name
+	event.Target = m.Target
+	event.Group = m.Group
+	event.CorrelationID = m.CorrelationID
+	return event
+}
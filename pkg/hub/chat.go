@@ -0,0 +1,122 @@
+package hub
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-chatroom/pkg/entity/model"
+	"go-chatroom/pkg/enum"
+	"sort"
+	"time"
+)
+
+// 公屏聊天
+func (h *Hub) read(m model.Message) {
+	fmt.Printf("%v 用户[%s]: %v \n", time.Now().Format("2006-01-02 15:04:05"), m.Name, m.Msg)
+
+	m.Area = enum.PublicScreen
+	quote, err := h.quote(m)
+	if err != nil {
+		h.reject(m, enum.PublicScreen, err.Error())
+		return
+	}
+
+	// 记录公屏消息到日志
+	h.log(m.Name, "", "", "Public", m.Msg, m.Timestamp)
+
+	event := newEvent(enum.ChatEvent, m)
+	event.Quote = quote
+	event = h.record(event)
+	h.ack(m, event, h.broadcast(event))
+}
+
+// 发送私聊消息
+func (h *Hub) sendPrivateMessage(m model.Message) {
+	fmt.Printf("%v 用户[%s] -> [%s]: %v \n", time.Now().Format("2006-01-02 15:04:05"), m.Name, m.Target, m.Msg)
+
+	// 记录私聊消息到日志
+	h.log(m.Name, m.Target, "", "Private", m.Msg, m.Timestamp)
+
+	sender, senderExists := h.clients[m.Name]
+	if !senderExists {
+		fmt.Printf("发送者 %s 不存在\n", m.Name)
+		return
+	}
+
+	if !h.accounts.Exists(m.Target) {
+		h.reject(m, enum.PrivateArea, fmt.Sprintf("用户 %s 不存在", m.Target))
+		return
+	}
+
+	m.Area = enum.PrivateArea
+	quote, err := h.quote(m)
+	if err != nil {
+		h.reject(m, enum.PrivateArea, err.Error())
+		return
+	}
+
+	// 构建私聊消息
+	privateMsg := newEvent(enum.ChatEvent, m)
+	privateMsg.Quote = quote
+	privateMsg = h.record(privateMsg)
+
+	// 发送给目标用户，不在线时等其上线后投递
+	online := h.deliver(m.Target, privateMsg)
+
+	// 发送给发送者确认
+	h.send(sender, privateMsg)
+
+	delivered := 0
+	if online {
+		delivered = 1
+	} else if m.CorrelationID == "" {
+		// 携带了 CorrelationID 的发送者通过 Ack 事件得知对方不在线
+		replyMsg := fmt.Sprintf("用户 %s 当前不在线，消息将在其上线后送达", m.Target)
+		h.send(sender, model.NewNotice(enum.NoticeEvent, enum.PrivateArea, replyMsg))
+	}
+	h.ack(m, privateMsg, delivered)
+}
+
+// 列出所有在线用户
+func (h *Hub) listUsers(m model.Message) {
+	users := make([]string, 0, len(h.clients))
+	for userName := range h.clients {
+		users = append(users, userName)
+	}
+	sort.Strings(users)
+
+	event := model.NewNotice(enum.UserListEvent, enum.PublicScreen, "")
+	event.List = users
+	h.sendTo(m.Name, event)
+}
+
+// 修改用户资料，Msg 为 model.User 的 JSON
+func (h *Hub) updUser(m model.Message) {
+	fmt.Printf("%v 用户[%s]: 修改用户信息 \n", time.Now().Format("2006-01-02 15:04:05"), m.Name)
+
+	var user model.User
+	// 解码 msg
+	err := json.Unmarshal([]byte(m.Msg), &user)
+	if err != nil {
+		return
+	}
+
+	client, exists := h.clients[m.Name]
+	if !exists {
+		return
+	}
+	client.User = user
+	h.clients[m.Name] = client
+
+	// 保存到账号中，下次登录时恢复
+	if err := h.accounts.UpdateUser(m.Name, user); err != nil {
+		fmt.Printf("保存用户[%s]资料失败: %v\n", m.Name, err)
+	}
+
+	fmt.Printf("%v 用户[%s]: 用户信息 %v \n", time.Now().Format("2006-01-02 15:04:05"), m.Name, user)
+
+	event := newEvent(enum.ProfileEvent, m)
+	event.Msg = ""
+	event.Area = enum.PublicScreen
+	event.User = &client.User
+	h.send(client, event)
+}
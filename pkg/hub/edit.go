@@ -0,0 +1,120 @@
+package hub
+
+import (
+	"fmt"
+	"go-chatroom/pkg/entity/model"
+	"go-chatroom/pkg/enum"
+	"strings"
+	"time"
+)
+
+// 修改消息，ID 为要修改的消息，Msg 为新内容，只有发送者本人可以修改
+func (h *Hub) editMessage(m model.Message) {
+	e, ok := h.findMessage(m)
+	if !ok {
+		return
+	}
+	if e.Name != m.Name {
+		h.sendTo(m.Name, model.NewNotice(enum.ErrorEvent, e.Area, "只能修改自己发送的消息"))
+		return
+	}
+	if strings.TrimSpace(m.Msg) == "" {
+		h.sendTo(m.Name, model.NewNotice(enum.ErrorEvent, e.Area, "消息内容不能为空"))
+		return
+	}
+
+	fmt.Printf("%v 用户[%s]: 修改消息 #%d \n", time.Now().Format("2006-01-02 15:04:05"), m.Name, e.ID)
+	h.log(m.Name, e.Target, e.Group, "Edit", fmt.Sprintf("#%d %s", e.ID, m.Msg), m.Timestamp)
+
+	e.Msg = m.Msg
+	e.EditedAt = m.Timestamp
+	h.update(e)
+}
+
+// 删除消息，ID 为要删除的消息，发送者本人可以删除，群主和管理员还可以删除其管理的成员的群聊消息
+func (h *Hub) deleteMessage(m model.Message) {
+	e, ok := h.findMessage(m)
+	if !ok {
+		return
+	}
+	if e.Name != m.Name {
+		g := h.groups[e.Group]
+		if e.Area != enum.GroupArea || !g.IsAdmin(m.Name) {
+			h.sendTo(m.Name, model.NewNotice(enum.ErrorEvent, e.Area, "只能删除自己发送的消息"))
+			return
+		}
+		if err := canModerate(g, m.Name, e.Name); err != nil {
+			h.sendTo(m.Name, model.NewNotice(enum.ErrorEvent, e.Area, err.Error()))
+			return
+		}
+	}
+
+	fmt.Printf("%v 用户[%s]: 删除消息 #%d \n", time.Now().Format("2006-01-02 15:04:05"), m.Name, e.ID)
+	h.log(m.Name, e.Target, e.Group, "Delete", fmt.Sprintf("#%d", e.ID), m.Timestamp)
+
+	e.Msg = ""
+	e.Reactions = nil
+	e.Deleted = true
+	e.DeletedBy = m.Name
+	e.EditedAt = m.Timestamp
+	h.update(e)
+}
+
+// findMessage 查找操作针对的消息，消息不存在、已删除或操作者看不到时向操作者返回错误
+func (h *Hub) findMessage(m model.Message) (model.Event, bool) {
+	if h.history == nil {
+		h.sendTo(m.Name, model.NewNotice(enum.ErrorEvent, m.Area, "服务端未保存消息历史，无法操作已发送的消息"))
+		return model.Event{}, false
+	}
+	e, ok := h.history.Get(m.ID)
+	if !ok || !h.visible(m.Name, e) {
+		h.sendTo(m.Name, model.NewNotice(enum.ErrorEvent, m.Area, fmt.Sprintf("消息 #%d 不存在", m.ID)))
+		return model.Event{}, false
+	}
+	if e.Deleted {
+		h.sendTo(m.Name, model.NewNotice(enum.ErrorEvent, e.Area, fmt.Sprintf("消息 #%d 已被删除", m.ID)))
+		return model.Event{}, false
+	}
+	return e, true
+}
+
+// update 保存修改后的消息，并通知能看到原消息的用户
+// 与原消息一样，不在线的私聊对象和群成员会在上线后收到
+func (h *Hub) update(e model.Event) {
+	h.save(e)
+
+	event := e
+	event.Kind = enum.MessageUpdateEvent
+	h.notify(e, event, true)
+}
+
+// save 将修改后的消息写入历史
+func (h *Hub) save(e model.Event) {
+	if err := h.history.Update(e); err != nil {
+		fmt.Printf("写入消息历史失败: %v\n", err)
+	}
+}
+
+// notify 将事件发给能看到消息 e 的用户，offline 为 true 时不在线的私聊对象和群成员上线后收到
+func (h *Hub) notify(e model.Event, event model.Event, offline bool) {
+	var names []string
+	switch e.Area {
+	case enum.PrivateArea:
+		names = []string{e.Target}
+		if e.Target != e.Name {
+			names = append(names, e.Name)
+		}
+	case enum.GroupArea:
+		names = h.groups[e.Group].MemberNames()
+	default:
+		h.broadcast(event)
+		return
+	}
+	for _, name := range names {
+		if offline {
+			h.deliver(name, event)
+		} else {
+			h.sendTo(name, event)
+		}
+	}
+}
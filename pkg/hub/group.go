@@ -0,0 +1,343 @@
+package hub
+
+import (
+	"fmt"
+	"go-chatroom/pkg/entity/model"
+	"go-chatroom/pkg/enum"
+	"sort"
+	"time"
+)
+
+// broadcastGroup 向群组内所有在线成员发送事件
+func (h *Hub) broadcastGroup(members []string, e model.Event) {
+	for _, memberName := range members {
+		h.sendTo(memberName, e)
+	}
+}
+
+// saveGroups 群组有修改后保存，写文件在后台进行，不阻塞事件循环
+func (h *Hub) saveGroups() {
+	if h.writer == nil {
+		return
+	}
+	if err := h.writer.Save(h.groups); err != nil {
+		fmt.Printf("保存群组信息失败: %v\n", err)
+	}
+}
+
+// groupError 向操作者返回群组相关的错误
+func (h *Hub) groupError(m model.Message, msg string) {
+	h.sendTo(m.Name, model.NewNotice(enum.ErrorEvent, enum.GroupArea, msg))
+}
+
+// memberEvent 构造群成员变动通知，Name 为操作者，Target 为变动的成员
+func memberEvent(kind enum.EventKind, m model.Message, member string) model.Event {
+	event := newEvent(kind, m)
+	event.Msg = ""
+	event.Target = member
+	event.Area = enum.GroupArea
+	return event
+}
+
+// manageGroup 校验群组存在且操作者拥有管理权限后执行 apply
+// ownerOnly 为 true 时只有群主可以操作，返回修改之后的成员列表
+func (h *Hub) manageGroup(m model.Message, ownerOnly bool, apply func(g *model.Group) error) ([]string, bool) {
+	g, exists := h.groups[m.Group]
+	if !exists {
+		h.groupError(m, fmt.Sprintf("群组 %s 不存在", m.Group))
+		return nil, false
+	}
+	if ownerOnly && g.Owner != m.Name {
+		h.groupError(m, "只有群主才能执行该操作")
+		return nil, false
+	}
+	if !g.IsAdmin(m.Name) {
+		h.groupError(m, fmt.Sprintf("你不是群组 %s 的群主或管理员", m.Group))
+		return nil, false
+	}
+	if m.Target == m.Name {
+		h.groupError(m, "不能对自己执行该操作")
+		return nil, false
+	}
+	if err := apply(g); err != nil {
+		h.groupError(m, err.Error())
+		return nil, false
+	}
+	h.saveGroups()
+	return g.MemberNames(), true
+}
+
+// canModerate 判断操作者能否管理目标成员：群主可以管理所有人，管理员只能管理普通成员
+func canModerate(g *model.Group, operator, target string) error {
+	if g.Owner == operator {
+		return nil
+	}
+	if g.IsAdmin(target) {
+		return fmt.Errorf("管理员不能管理群主或其他管理员")
+	}
+	return nil
+}
+
+// 发送群聊消息
+func (h *Hub) sendGroupMessage(m model.Message) {
+	fmt.Printf("%v 群组[%s] 用户[%s]: %v \n", time.Now().Format("2006-01-02 15:04:05"), m.Group, m.Name, m.Msg)
+
+	g, exists := h.groups[m.Group]
+	if !exists {
+		h.reject(m, enum.GroupArea, fmt.Sprintf("群组 %s 不存在", m.Group))
+		return
+	}
+	if !g.IsMember(m.Name) {
+		h.reject(m, enum.GroupArea, fmt.Sprintf("你不是群组 %s 的成员", m.Group))
+		return
+	}
+
+	m.Area = enum.GroupArea
+	quote, err := h.quote(m)
+	if err != nil {
+		h.reject(m, enum.GroupArea, err.Error())
+		return
+	}
+
+	// 记录群聊消息到日志
+	h.log(m.Name, "", m.Group, "Group", m.Msg, m.Timestamp)
+
+	// 构建群聊消息
+	groupMsg := newEvent(enum.ChatEvent, m)
+	groupMsg.Quote = quote
+	groupMsg = h.record(groupMsg)
+
+	// 发送给群组内所有成员，不在线的成员上线后投递
+	h.ack(m, groupMsg, h.deliverGroup(g.MemberNames(), groupMsg))
+}
+
+// 创建群组，Msg 为群组名称
+func (h *Hub) createGroup(m model.Message) {
+	// 检查群组是否已存在
+	if _, exists := h.groups[m.Msg]; exists {
+		h.sendTo(m.Name, model.NewNotice(enum.ErrorEvent, enum.PublicScreen, fmt.Sprintf("群组 %s 已存在", m.Msg)))
+		return
+	}
+
+	// 创建新群组，创建者成为群主，之前解散的同名群组的记录不属于新群组
+	g := model.NewGroup(m.Msg, m.Name, m.Timestamp)
+	g.Since = h.lastMessageID()
+	h.groups[m.Msg] = g
+	h.saveGroups()
+
+	h.sendTo(m.Name, model.NewNotice(enum.NoticeEvent, enum.PublicScreen, fmt.Sprintf("成功创建群组 %s 并成为群主", m.Msg)))
+}
+
+// 加入群组
+func (h *Hub) joinGroup(m model.Message) {
+	g, exists := h.groups[m.Group]
+	if !exists {
+		h.groupError(m, fmt.Sprintf("群组 %s 不存在", m.Group))
+		return
+	}
+	if g.IsMember(m.Name) {
+		h.groupError(m, fmt.Sprintf("你已经是群组 %s 的成员", m.Group))
+		return
+	}
+	if g.IsBanned(m.Name) {
+		h.groupError(m, fmt.Sprintf("你已被群组 %s 封禁", m.Group))
+		return
+	}
+	g.AddMember(m.Name)
+	h.saveGroups()
+
+	fmt.Printf("%v 群组[%s] 用户[%s]: 加入群组 \n", time.Now().Format("2006-01-02 15:04:05"), m.Group, m.Name)
+	h.log(m.Name, "", m.Group, "System", "Join Group", m.Timestamp)
+
+	// 通知包括新成员在内的所有成员
+	h.broadcastGroup(g.MemberNames(), memberEvent(enum.GroupJoinEvent, m, m.Name))
+}
+
+// 退出群组
+func (h *Hub) leaveGroup(m model.Message) {
+	g, exists := h.groups[m.Group]
+	if !exists || !g.IsMember(m.Name) {
+		h.groupError(m, fmt.Sprintf("你不是群组 %s 的成员", m.Group))
+		return
+	}
+	if g.Owner == m.Name {
+		h.groupError(m, "群主不能退出群组，请先转让群主或解散群组")
+		return
+	}
+	g.RemoveMember(m.Name)
+	h.saveGroups()
+
+	fmt.Printf("%v 群组[%s] 用户[%s]: 退出群组 \n", time.Now().Format("2006-01-02 15:04:05"), m.Group, m.Name)
+	h.log(m.Name, "", m.Group, "System", "Leave Group", m.Timestamp)
+
+	// 通知剩余成员以及退出者本人
+	h.broadcastGroup(append(g.MemberNames(), m.Name), memberEvent(enum.GroupLeaveEvent, m, m.Name))
+}
+
+// 邀请用户加入群组，Target 为被邀请的用户
+func (h *Hub) inviteToGroup(m model.Message) {
+	if !h.accounts.Exists(m.Target) {
+		h.groupError(m, fmt.Sprintf("用户 %s 不存在", m.Target))
+		return
+	}
+
+	g, exists := h.groups[m.Group]
+	if !exists || !g.IsMember(m.Name) {
+		h.groupError(m, fmt.Sprintf("你不是群组 %s 的成员", m.Group))
+		return
+	}
+	if g.IsMember(m.Target) {
+		h.groupError(m, fmt.Sprintf("%s 已经是群组 %s 的成员", m.Target, m.Group))
+		return
+	}
+	if g.IsBanned(m.Target) {
+		h.groupError(m, fmt.Sprintf("%s 已被群组 %s 封禁", m.Target, m.Group))
+		return
+	}
+	g.AddMember(m.Target)
+	h.saveGroups()
+
+	fmt.Printf("%v 群组[%s] 用户[%s]: 邀请 %s 加入群组 \n", time.Now().Format("2006-01-02 15:04:05"), m.Group, m.Name, m.Target)
+	h.log(m.Name, m.Target, m.Group, "System", "Invite To Group", m.Timestamp)
+
+	// 通知包括被邀请者在内的所有成员
+	h.broadcastGroup(g.MemberNames(), memberEvent(enum.GroupJoinEvent, m, m.Target))
+}
+
+// setRole 由群主修改成员角色：设为管理员、取消管理员或转让群主
+func (h *Hub) setRole(m model.Message, role enum.GroupRole) {
+	members, ok := h.manageGroup(m, true, func(g *model.Group) error {
+		if !g.IsMember(m.Target) {
+			return fmt.Errorf("%s 不是群组 %s 的成员", m.Target, m.Group)
+		}
+		if g.Members[m.Target] == role {
+			return fmt.Errorf("%s 已经是%s", m.Target, role.RoleName())
+		}
+		g.SetRole(m.Target, role)
+		return nil
+	})
+	if !ok {
+		return
+	}
+
+	fmt.Printf("%v 群组[%s] 用户[%s]: 将 %s 设为%s \n", time.Now().Format("2006-01-02 15:04:05"), m.Group, m.Name, m.Target, role.RoleName())
+	h.log(m.Name, m.Target, m.Group, "System", "Set Role "+string(role), m.Timestamp)
+
+	event := memberEvent(enum.GroupRoleEvent, m, m.Target)
+	event.Msg = string(role)
+	h.broadcastGroup(members, event)
+}
+
+// 将成员移出群组
+func (h *Hub) kickMember(m model.Message) {
+	members, ok := h.manageGroup(m, false, func(g *model.Group) error {
+		if !g.IsMember(m.Target) {
+			return fmt.Errorf("%s 不是群组 %s 的成员", m.Target, m.Group)
+		}
+		if err := canModerate(g, m.Name, m.Target); err != nil {
+			return err
+		}
+		g.RemoveMember(m.Target)
+		return nil
+	})
+	if !ok {
+		return
+	}
+
+	fmt.Printf("%v 群组[%s] 用户[%s]: 将 %s 移出群组 \n", time.Now().Format("2006-01-02 15:04:05"), m.Group, m.Name, m.Target)
+	h.log(m.Name, m.Target, m.Group, "System", "Kick Member", m.Timestamp)
+
+	// 通知剩余成员以及被移出的成员
+	h.broadcastGroup(append(members, m.Target), memberEvent(enum.GroupLeaveEvent, m, m.Target))
+}
+
+// 封禁用户，若其为成员则同时移出群组
+func (h *Hub) banMember(m model.Message) {
+	members, ok := h.manageGroup(m, false, func(g *model.Group) error {
+		if g.IsBanned(m.Target) {
+			return fmt.Errorf("%s 已被封禁", m.Target)
+		}
+		if g.IsMember(m.Target) {
+			if err := canModerate(g, m.Name, m.Target); err != nil {
+				return err
+			}
+		} else if !h.accounts.Exists(m.Target) {
+			return fmt.Errorf("用户 %s 不存在", m.Target)
+		}
+		g.Ban(m.Target)
+		return nil
+	})
+	if !ok {
+		return
+	}
+
+	fmt.Printf("%v 群组[%s] 用户[%s]: 封禁 %s \n", time.Now().Format("2006-01-02 15:04:05"), m.Group, m.Name, m.Target)
+	h.log(m.Name, m.Target, m.Group, "System", "Ban Member", m.Timestamp)
+
+	h.broadcastGroup(append(members, m.Target), memberEvent(enum.GroupBanEvent, m, m.Target))
+}
+
+// 解除封禁
+func (h *Hub) unbanMember(m model.Message) {
+	members, ok := h.manageGroup(m, false, func(g *model.Group) error {
+		if !g.IsBanned(m.Target) {
+			return fmt.Errorf("%s 未被封禁", m.Target)
+		}
+		g.Unban(m.Target)
+		return nil
+	})
+	if !ok {
+		return
+	}
+
+	fmt.Printf("%v 群组[%s] 用户[%s]: 解除封禁 %s \n", time.Now().Format("2006-01-02 15:04:05"), m.Group, m.Name, m.Target)
+	h.log(m.Name, m.Target, m.Group, "System", "Unban Member", m.Timestamp)
+
+	h.broadcastGroup(append(members, m.Target), memberEvent(enum.GroupUnbanEvent, m, m.Target))
+}
+
+// 解散群组
+func (h *Hub) dissolveGroup(m model.Message) {
+	// 解散不涉及目标用户，清空 Target 以免触发对自身操作的校验
+	m.Target = ""
+	members, ok := h.manageGroup(m, true, func(g *model.Group) error {
+		delete(h.groups, g.Name)
+		return nil
+	})
+	if !ok {
+		return
+	}
+
+	fmt.Printf("%v 群组[%s] 用户[%s]: 解散群组 \n", time.Now().Format("2006-01-02 15:04:05"), m.Group, m.Name)
+	h.log(m.Name, "", m.Group, "System", "Dissolve Group", m.Timestamp)
+
+	h.broadcastGroup(members, memberEvent(enum.GroupDissolveEvent, m, ""))
+}
+
+// 列出群组成员及其角色
+func (h *Hub) listGroupMembers(m model.Message) {
+	g, exists := h.groups[m.Group]
+	if !exists {
+		h.groupError(m, fmt.Sprintf("群组 %s 不存在", m.Group))
+		return
+	}
+
+	event := model.NewNotice(enum.GroupMembersEvent, enum.GroupArea, "")
+	event.Group = m.Group
+	event.List = g.MemberNames()
+	event.Roles = g.Roles()
+	h.sendTo(m.Name, event)
+}
+
+// 列出所有群组
+func (h *Hub) listGroups(m model.Message) {
+	groups := make([]string, 0, len(h.groups))
+	for groupName := range h.groups {
+		groups = append(groups, groupName)
+	}
+	sort.Strings(groups)
+
+	event := model.NewNotice(enum.GroupListEvent, enum.PublicScreen, "")
+	event.List = groups
+	h.sendTo(m.Name, event)
+}
@@ -0,0 +1,133 @@
+package hub
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-chatroom/pkg/entity/model"
+	"go-chatroom/pkg/enum"
+	"go-chatroom/pkg/history"
+	"sort"
+)
+
+// record 将聊天消息写入历史并分配消息ID，写入失败时消息照常发送
+// 未启用历史时由聊天中心自行分配递增的 ID
+func (h *Hub) record(e model.Event) model.Event {
+	if h.history == nil {
+		h.lastID++
+		e.ID = h.lastID
+		return e
+	}
+	stored, err := h.history.Append(e)
+	if err != nil {
+		fmt.Printf("写入消息历史失败: %v\n", err)
+	}
+	return stored
+}
+
+// resume 合并离线消息和断线期间错过的消息，按 ID 去重后按顺序排列
+// 错过的消息超过补发上限时只补发最近的部分，truncated 为 true
+func (h *Hub) resume(name string, lastID uint64, pending []model.Event) (events []model.Event, truncated bool) {
+	if h.history == nil {
+		return pending, false
+	}
+	missed, truncated := h.history.Since(lastID, func(e model.Event) bool {
+		return h.visible(name, e)
+	})
+
+	seen := make(map[uint64]bool, len(missed))
+	for _, e := range missed {
+		seen[e.ID] = true
+	}
+	// 离线消息通常已经包含在历史中，历史写入失败、没有 ID 的消息放在最后
+	// 补发的历史已是修改后的内容，其余消息的修改通知放在补发的消息之后
+	var updates, unrecorded []model.Event
+	for _, e := range pending {
+		switch {
+		case e.ID == 0:
+			unrecorded = append(unrecorded, e)
+		case e.Kind == enum.MessageUpdateEvent:
+			if !seen[e.ID] {
+				updates = append(updates, e)
+			}
+		case e.ID > lastID && !seen[e.ID]:
+			missed = append(missed, e)
+			seen[e.ID] = true
+		}
+	}
+	sort.Slice(missed, func(i, j int) bool {
+		return missed[i].ID < missed[j].ID
+	})
+	missed = append(missed, updates...)
+	return append(missed, unrecorded...), truncated
+}
+
+// lastMessageID 返回最后分配的消息 ID，用于区分解散前后的同名群组
+func (h *Hub) lastMessageID() uint64 {
+	if h.history == nil {
+		return h.lastID
+	}
+	return h.history.LastID()
+}
+
+// visible 判断用户能否看到这条历史消息
+func (h *Hub) visible(name string, e model.Event) bool {
+	switch e.Area {
+	case enum.PrivateArea:
+		return e.Name == name || e.Target == name
+	case enum.GroupArea:
+		g, exists := h.groups[e.Group]
+		return exists && g.IsMember(name) && g.Contains(e)
+	default:
+		return true
+	}
+}
+
+// 查询历史消息，Area 指定公屏、群聊(Group)或私聊(Target)，Msg 为 model.HistoryQuery 的 JSON
+func (h *Hub) fetchHistory(m model.Message) {
+	var q model.HistoryQuery
+	if m.Msg != "" {
+		if err := json.Unmarshal([]byte(m.Msg), &q); err != nil {
+			h.sendTo(m.Name, model.NewNotice(enum.ErrorEvent, m.Area, "历史消息查询参数错误"))
+			return
+		}
+	}
+
+	query := history.Query{
+		Area:     m.Area,
+		Limit:    q.Limit,
+		BeforeID: q.BeforeID,
+		Before:   q.Before,
+	}
+	switch m.Area {
+	case enum.GroupArea:
+		// 只有群成员可以查看群聊记录
+		g, exists := h.groups[m.Group]
+		if !exists || !g.IsMember(m.Name) {
+			h.groupError(m, fmt.Sprintf("你不是群组 %s 的成员", m.Group))
+			return
+		}
+		query.Group = m.Group
+		query.AfterID = g.Since
+	case enum.PrivateArea:
+		if m.Target == "" {
+			h.sendTo(m.Name, model.NewNotice(enum.ErrorEvent, enum.PrivateArea, "请指定私聊对象"))
+			return
+		}
+		// 私聊记录只能查询自己参与的会话
+		query.User = m.Name
+		query.Peer = m.Target
+	default:
+		query.Area = enum.PublicScreen
+	}
+
+	var records []model.Event
+	if h.history != nil {
+		records = h.history.Query(query)
+	}
+
+	event := model.NewNotice(enum.HistoryEvent, query.Area, "")
+	event.Group = query.Group
+	event.Target = query.Peer
+	event.History = records
+	h.sendTo(m.Name, event)
+}
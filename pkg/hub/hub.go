@@ -0,0 +1,359 @@
+package hub
+
+import (
+	"errors"
+	"fmt"
+	"go-chatroom/pkg/account"
+	"go-chatroom/pkg/entity/model"
+	"go-chatroom/pkg/enum"
+	"go-chatroom/pkg/group"
+	"go-chatroom/pkg/history"
+	"go-chatroom/pkg/log"
+	"go-chatroom/pkg/offline"
+	"time"
+)
+
+// ErrStopped 聊天中心已停止
+var ErrStopped = errors.New("hub: stopped")
+
+// Options 聊天中心依赖的存储，除 Accounts 外都可以为空
+type Options struct {
+	Accounts account.Store   // 账号存储
+	History  *history.Store  // 消息历史存储，为空时不记录历史
+	Offline  *offline.Queue  // 离线消息队列，为空时不保存离线消息
+	Logger   *log.ChatLogger // 聊天日志记录器，为空时不写日志
+
+	Groups      map[string]*model.Group // 启动时恢复的群组，为空时从零开始
+	GroupWriter *group.Writer           // 群组有修改时保存，为空时不保存
+}
+
+// Hub 聊天中心，在线用户和群组只由事件循环协程访问，因此不需要互斥锁
+// 所有状态修改都通过 Register、Unregister、Broadcast、Route 提交给事件循环按顺序执行
+type Hub struct {
+	accounts account.Store
+	history  *history.Store
+	offline  *offline.Queue
+	logger   *log.ChatLogger
+	writer   *group.Writer
+
+	clients map[string]model.Client // 在线用户
+	groups  map[string]*model.Group // 群组信息
+	closing bool                    // 服务端正在关闭，不再接受登录
+	lastID  uint64                  // 未启用历史时最后分配的消息ID
+	typing  map[typingKey]time.Time // 每个会话最后一次转发输入状态的时间
+
+	actions chan func()   // 待事件循环执行的操作
+	quit    chan struct{} // 停止信号
+	stopped chan struct{} // 事件循环退出后关闭
+}
+
+// New 创建聊天中心，需要调用 Run 启动事件循环
+func New(opts Options) *Hub {
+	groups := opts.Groups
+	if groups == nil {
+		groups = make(map[string]*model.Group)
+	}
+	return &Hub{
+		accounts: opts.Accounts,
+		history:  opts.History,
+		offline:  opts.Offline,
+		logger:   opts.Logger,
+		writer:   opts.GroupWriter,
+		clients:  make(map[string]model.Client),
+		groups:   groups,
+		typing:   make(map[typingKey]time.Time),
+		actions:  make(chan func(), 256),
+		quit:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+}
+
+// Run 运行事件循环，直到 Stop 被调用
+func (h *Hub) Run() {
+	defer close(h.stopped)
+
+	for {
+		select {
+		case action := <-h.actions:
+			action()
+		case <-h.quit:
+			return
+		}
+	}
+}
+
+// Stop 停止事件循环并等待其退出，之后提交的操作都会被忽略
+func (h *Hub) Stop() {
+	select {
+	case <-h.quit:
+	default:
+		close(h.quit)
+	}
+	<-h.stopped
+}
+
+// do 将操作提交给事件循环，聊天中心已停止时返回 false
+func (h *Hub) do(action func()) bool {
+	select {
+	case h.actions <- action:
+		return true
+	case <-h.quit:
+		return false
+	}
+}
+
+// call 将操作提交给事件循环并等待其执行完毕
+func (h *Hub) call(action func()) error {
+	done := make(chan struct{})
+	ok := h.do(func() {
+		defer close(done)
+		action()
+	})
+	if !ok {
+		return ErrStopped
+	}
+	select {
+	case <-done:
+		return nil
+	case <-h.stopped:
+		return ErrStopped
+	}
+}
+
+// Register 将已通过认证的用户绑定到会话上，同名用户已在线时返回错误
+// 成功后依次向该用户发送登录确认和离线消息，并通知所有人新用户上线
+// lastID 不为 0 时表示断线重连，还会补发 ID 大于 lastID 且该用户可见的消息
+func (h *Hub) Register(sess model.Session, name string, user model.User, lastID uint64) error {
+	var err error
+	callErr := h.call(func() {
+		err = h.register(sess, name, user, lastID)
+	})
+	if callErr != nil {
+		return callErr
+	}
+	return err
+}
+
+// Unregister 用户下线，只有会话与登记的会话一致时才会移除
+// 等待下线处理完毕后返回，之后同名用户可以立即重新登录
+func (h *Hub) Unregister(name string, sess model.Session) {
+	h.call(func() {
+		h.unregister(name, sess)
+	})
+}
+
+// Broadcast 向所有在线用户发送事件
+func (h *Hub) Broadcast(e model.Event) {
+	h.do(func() {
+		h.broadcast(e)
+	})
+}
+
+// SendTo 向在线用户发送事件，用户不在线时忽略
+func (h *Hub) SendTo(name string, e model.Event) {
+	h.do(func() {
+		h.sendTo(name, e)
+	})
+}
+
+// Route 按 Op 处理已登录用户发来的消息，m.Name 必须是会话绑定的用户名
+func (h *Hub) Route(m model.Message) {
+	h.do(func() {
+		h.route(m)
+	})
+}
+
+// Online 返回在线用户数
+func (h *Hub) Online() int {
+	n := 0
+	h.call(func() {
+		n = len(h.clients)
+	})
+	return n
+}
+
+// Shutdown 向所有在线用户发送关闭通知并将其全部下线，之后不再接受登录
+// 返回这些用户的会话，由调用方等待发送队列写完后关闭
+func (h *Hub) Shutdown(e model.Event) []model.Session {
+	var sessions []model.Session
+	h.call(func() {
+		h.closing = true
+		h.broadcast(e)
+
+		now := time.Now().Unix()
+		for name, client := range h.clients {
+			h.log(name, "", "", "System", "User Logout", now)
+			sessions = append(sessions, client.Session)
+		}
+		h.clients = make(map[string]model.Client)
+	})
+	return sessions
+}
+
+func (h *Hub) route(m model.Message) {
+	switch m.Op {
+	case enum.Chat:
+		h.read(m)
+	case enum.PrivateChat:
+		h.sendPrivateMessage(m)
+	case enum.GroupChat:
+		h.sendGroupMessage(m)
+	case enum.CreateGroup:
+		h.createGroup(m)
+	case enum.ListGroups:
+		h.listGroups(m)
+	case enum.ListUsers:
+		h.listUsers(m)
+	case enum.JoinGroup:
+		h.joinGroup(m)
+	case enum.LeaveGroup:
+		h.leaveGroup(m)
+	case enum.InviteToGroup:
+		h.inviteToGroup(m)
+	case enum.ListGroupMembers:
+		h.listGroupMembers(m)
+	case enum.PromoteAdmin:
+		h.setRole(m, enum.GroupAdmin)
+	case enum.DemoteAdmin:
+		h.setRole(m, enum.GroupMember)
+	case enum.KickMember:
+		h.kickMember(m)
+	case enum.BanMember:
+		h.banMember(m)
+	case enum.UnbanMember:
+		h.unbanMember(m)
+	case enum.TransferGroup:
+		// 转让群主，原群主成为管理员
+		h.setRole(m, enum.GroupOwner)
+	case enum.DissolveGroup:
+		h.dissolveGroup(m)
+	case enum.FetchHistory:
+		h.fetchHistory(m)
+	case enum.MarkRead:
+		h.markRead(m)
+	case enum.Typing:
+		h.typingNotice(m)
+	case enum.EditMessage:
+		h.editMessage(m)
+	case enum.DeleteMessage:
+		h.deleteMessage(m)
+	case enum.React:
+		h.react(m, true)
+	case enum.Unreact:
+		h.react(m, false)
+	case enum.UpdateUser:
+		h.updUser(m)
+	default:
+		fmt.Println("无效OP")
+	}
+}
+
+func (h *Hub) register(sess model.Session, name string, user model.User, lastID uint64) error {
+	if h.closing {
+		return errors.New("服务器正在关闭")
+	}
+	if _, exists := h.clients[name]; exists {
+		return fmt.Errorf("用户 %s 已在线", name)
+	}
+	client := model.Client{
+		Session: sess,
+		Name:    name,
+		User:    user,
+	}
+	h.clients[name] = client
+
+	now := time.Now().Unix()
+	fmt.Printf("%v 用户[%s]: 登录 \n", time.Now().Format("2006-01-02 15:04:05"), name)
+
+	// 事件循环中依次处理，离线消息一定先于之后的新消息到达
+	pending := h.takeOffline(name)
+	var truncated bool
+	if lastID > 0 {
+		pending, truncated = h.resume(name, lastID, pending)
+	}
+
+	ack := model.NewNotice(enum.LoginAckEvent, enum.PublicScreen, "")
+	ack.Name = name
+	ack.User = &user
+	ack.Pending = len(pending)
+	ack.Truncated = truncated
+	h.send(client, ack)
+	h.flushOffline(client, pending)
+
+	// 提醒所有人新用户上线
+	h.log(name, "", "", "System", "User Login", now)
+	event := model.NewNotice(enum.LoginEvent, enum.PublicScreen, "")
+	event.Name = name
+	h.broadcast(event)
+	return nil
+}
+
+func (h *Hub) unregister(name string, sess model.Session) {
+	client, exists := h.clients[name]
+	if !exists || client.Session != sess {
+		return
+	}
+	delete(h.clients, name)
+	h.clearTyping(name)
+
+	now := time.Now().Unix()
+	fmt.Printf("%v 用户[%s]: 退出 \n", time.Now().Format("2006-01-02 15:04:05"), name)
+	h.log(name, "", "", "System", "User Logout", now)
+
+	// 关闭时会等待发送队列写完，放到单独的协程中以免阻塞事件循环
+	go client.Session.Close()
+
+	// 遍历其余连接进行离线通知
+	event := model.NewNotice(enum.LogoutEvent, enum.PublicScreen, "")
+	event.Name = name
+	h.broadcast(event)
+}
+
+// broadcast 向所有在线用户发送事件，返回除 e.Name 以外成功发送的用户数
+func (h *Hub) broadcast(e model.Event) int {
+	n := 0
+	for name, client := range h.clients {
+		if h.send(client, e) && name != e.Name {
+			n++
+		}
+	}
+	return n
+}
+
+// send 通过客户端的会话发送事件，会话带有发送队列，不会阻塞事件循环
+// 返回事件是否进入了发送队列
+func (h *Hub) send(client model.Client, e model.Event) bool {
+	if err := client.Session.Send(e); err != nil {
+		fmt.Printf("client Conn Error for %s: %v\n", client.Name, err)
+		return false
+	}
+	return true
+}
+
+// sendTo 向在线用户发送事件，用户不在线时忽略
+func (h *Hub) sendTo(name string, e model.Event) {
+	if client, exists := h.clients[name]; exists {
+		h.send(client, e)
+	}
+}
+
+// log 写入聊天日志
+func (h *Hub) log(sender, receiver, group, msgType, content string, timestamp int64) {
+	if h.logger != nil {
+		h.logger.LogMessage(sender, receiver, group, msgType, content, timestamp)
+	}
+}
+
+// newEvent 根据客户端消息构造事件
+func newEvent(kind enum.EventKind, m model.Message) model.Event {
+	return model.Event{
+		Kind:      kind,
+		Name:      m.Name,
+		Msg:       m.Msg,
+		Target:    m.Target,
+		Group:     m.Group,
+		Timestamp: m.Timestamp,
+		Area:      m.Area,
+		ReplyTo:   m.ReplyTo,
+	}
+}
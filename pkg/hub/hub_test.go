@@ -0,0 +1,254 @@
+package hub
+
+import (
+	"fmt"
+	"go-chatroom/pkg/account"
+	"go-chatroom/pkg/entity/model"
+	"go-chatroom/pkg/enum"
+	"go-chatroom/pkg/offline"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// fakeSession 记录收到的事件，代替真实的连接
+type fakeSession struct {
+	id     string
+	mu     sync.Mutex
+	events []model.Event
+}
+
+func (s *fakeSession) ID() string           { return s.id }
+func (s *fakeSession) Close() error         { return nil }
+func (s *fakeSession) RemoteAddr() net.Addr { return &net.TCPAddr{} }
+
+func (s *fakeSession) Send(e model.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, e)
+	return nil
+}
+
+// take 取出并清空已收到的事件
+func (s *fakeSession) take() []model.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	events := s.events
+	s.events = nil
+	return events
+}
+
+// find 取出已收到的事件，返回其中第一条指定类型的事件
+func (s *fakeSession) find(kind enum.EventKind) (model.Event, bool) {
+	for _, e := range s.take() {
+		if e.Kind == kind {
+			return e, true
+		}
+	}
+	return model.Event{}, false
+}
+
+// fakeAccounts 只记录用户名的账号存储，不校验密码
+type fakeAccounts map[string]bool
+
+func (a fakeAccounts) Register(name, password string) error { a[name] = true; return nil }
+func (a fakeAccounts) Exists(name string) bool              { return a[name] }
+
+func (a fakeAccounts) Authenticate(name, password string) (account.Account, error) {
+	return account.Account{Name: name}, nil
+}
+
+func (a fakeAccounts) UpdateUser(name string, user model.User) error { return nil }
+
+type testHub struct {
+	*Hub
+	t        *testing.T
+	sessions map[string]*fakeSession
+}
+
+func newTestHub(t *testing.T, users ...string) *testHub {
+	t.Helper()
+	queue, err := offline.NewQueue(filepath.Join(t.TempDir(), "offline.json"))
+	if err != nil {
+		t.Fatalf("NewQueue: %v", err)
+	}
+	accounts := make(fakeAccounts)
+	for _, name := range users {
+		accounts[name] = true
+	}
+
+	h := New(Options{Accounts: accounts, Offline: queue})
+	go h.Run()
+	t.Cleanup(func() {
+		h.Stop()
+		queue.Close()
+	})
+	return &testHub{Hub: h, t: t, sessions: make(map[string]*fakeSession)}
+}
+
+// login 以新的会话登录，并丢弃登录时收到的事件
+func (h *testHub) login(name string) *fakeSession {
+	h.t.Helper()
+	sess := &fakeSession{id: fmt.Sprintf("%s-%d", name, len(h.sessions))}
+	if err := h.Register(sess, name, model.User{}, 0); err != nil {
+		h.t.Fatalf("Register(%s): %v", name, err)
+	}
+	h.sessions[name] = sess
+	h.clear()
+	return sess
+}
+
+// route 处理消息并等待事件循环执行完毕
+func (h *testHub) route(m model.Message) {
+	h.Route(m)
+	h.Online()
+}
+
+// members 返回群组当前的成员，群组不存在时返回 false
+func (h *testHub) members(name string) (map[string]enum.GroupRole, bool) {
+	var roles map[string]enum.GroupRole
+	var exists bool
+	h.call(func() {
+		if g, ok := h.groups[name]; ok {
+			roles, exists = g.Roles(), true
+		}
+	})
+	return roles, exists
+}
+
+// clear 丢弃所有会话已收到的事件
+func (h *testHub) clear() {
+	for _, sess := range h.sessions {
+		sess.take()
+	}
+}
+
+func TestRegisterDuplicate(t *testing.T) {
+	h := newTestHub(t, "alice")
+	first := &fakeSession{id: "1"}
+	if err := h.Register(first, "alice", model.User{}, 0); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if _, ok := first.find(enum.LoginAckEvent); !ok {
+		t.Fatal("no login_ack sent")
+	}
+
+	second := &fakeSession{id: "2"}
+	if err := h.Register(second, "alice", model.User{}, 0); err == nil {
+		t.Fatal("duplicate login accepted")
+	}
+	if h.Online() != 1 {
+		t.Fatalf("Online = %d, want 1", h.Online())
+	}
+
+	// 其他会话不能让已登录的用户下线
+	h.Unregister("alice", second)
+	if h.Online() != 1 {
+		t.Fatal("unregister with a foreign session removed the user")
+	}
+	h.Unregister("alice", first)
+	if err := h.Register(second, "alice", model.User{}, 0); err != nil {
+		t.Fatalf("Register after logout: %v", err)
+	}
+}
+
+func TestPrivateChatToOfflineUser(t *testing.T) {
+	h := newTestHub(t, "alice", "bob")
+	alice := h.login("alice")
+
+	h.route(model.Message{Name: "alice", Op: enum.PrivateChat, Target: "bob", Msg: "hi", CorrelationID: "c1"})
+	ack, ok := alice.find(enum.AckEvent)
+	if !ok || ack.Status != enum.StatusSent || ack.CorrelationID != "c1" {
+		t.Fatalf("ack = %+v, want status sent", ack)
+	}
+
+	h.route(model.Message{Name: "alice", Op: enum.PrivateChat, Target: "nobody", Msg: "hi", CorrelationID: "c2"})
+	if ack, _ := alice.find(enum.AckEvent); ack.Status != enum.StatusFailed {
+		t.Fatalf("ack = %+v, want status failed", ack)
+	}
+
+	bob := &fakeSession{id: "bob"}
+	if err := h.Register(bob, "bob", model.User{}, 0); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	events := bob.take()
+	if len(events) < 2 || events[0].Kind != enum.LoginAckEvent || events[0].Pending != 1 {
+		t.Fatalf("events = %+v, want login_ack with one pending message", events)
+	}
+	if e := events[1]; e.Kind != enum.ChatEvent || e.Name != "alice" || e.Msg != "hi" {
+		t.Fatalf("offline message = %+v", e)
+	}
+}
+
+func TestGroupPermissions(t *testing.T) {
+	h := newTestHub(t, "alice", "bob", "carol")
+	alice, bob, carol := h.login("alice"), h.login("bob"), h.login("carol")
+
+	h.route(model.Message{Name: "alice", Op: enum.CreateGroup, Msg: "g"})
+	h.route(model.Message{Name: "bob", Op: enum.JoinGroup, Group: "g"})
+	h.clear()
+
+	// 非成员不能在群里发言
+	h.route(model.Message{Name: "carol", Op: enum.GroupChat, Group: "g", Msg: "hi"})
+	if _, ok := carol.find(enum.ErrorEvent); !ok {
+		t.Fatal("non-member sent a group message")
+	}
+	if _, ok := bob.find(enum.ChatEvent); ok {
+		t.Fatal("member received a message from a non-member")
+	}
+
+	// 普通成员不能管理群组
+	h.route(model.Message{Name: "bob", Op: enum.KickMember, Group: "g", Target: "alice"})
+	h.route(model.Message{Name: "bob", Op: enum.DissolveGroup, Group: "g"})
+	if members, _ := h.members("g"); members["alice"] == "" {
+		t.Fatal("member kicked the owner")
+	}
+	if _, ok := bob.find(enum.ErrorEvent); !ok {
+		t.Fatal("no error for an unauthorized operation")
+	}
+
+	// 管理员不能管理群主，但可以移出普通成员
+	h.route(model.Message{Name: "alice", Op: enum.PromoteAdmin, Group: "g", Target: "bob"})
+	h.route(model.Message{Name: "carol", Op: enum.JoinGroup, Group: "g"})
+	h.clear()
+	h.route(model.Message{Name: "bob", Op: enum.KickMember, Group: "g", Target: "alice"})
+	if _, ok := bob.find(enum.ErrorEvent); !ok {
+		t.Fatal("admin kicked the owner")
+	}
+	h.route(model.Message{Name: "bob", Op: enum.KickMember, Group: "g", Target: "carol"})
+	if e, ok := carol.find(enum.GroupLeaveEvent); !ok || e.Target != "carol" {
+		t.Fatal("kicked member was not notified")
+	}
+
+	// 只有群主可以解散群组
+	h.route(model.Message{Name: "bob", Op: enum.DissolveGroup, Group: "g"})
+	if _, exists := h.members("g"); !exists {
+		t.Fatal("admin dissolved the group")
+	}
+	h.route(model.Message{Name: "alice", Op: enum.DissolveGroup, Group: "g"})
+	if _, exists := h.members("g"); exists {
+		t.Fatal("owner could not dissolve the group")
+	}
+	if _, ok := alice.find(enum.GroupDissolveEvent); !ok {
+		t.Fatal("owner was not notified of the dissolution")
+	}
+}
+
+func TestReadReceiptWithoutHistory(t *testing.T) {
+	h := newTestHub(t, "alice", "bob")
+	alice, bob := h.login("alice"), h.login("bob")
+
+	h.route(model.Message{Name: "alice", Op: enum.PrivateChat, Target: "bob", Msg: "hi"})
+	e, _ := bob.find(enum.ChatEvent)
+	h.clear()
+
+	// 没有消息历史时无法校验，回执不会转发给对方
+	h.route(model.Message{Name: "bob", Op: enum.MarkRead, Target: "alice", ID: e.ID})
+	if _, ok := alice.find(enum.ReadReceiptEvent); ok {
+		t.Fatal("read receipt forwarded without validation")
+	}
+	if _, ok := bob.find(enum.ErrorEvent); !ok {
+		t.Fatal("reader was not told the receipt was rejected")
+	}
+}
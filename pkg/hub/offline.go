@@ -0,0 +1,61 @@
+package hub
+
+import (
+	"fmt"
+	"go-chatroom/pkg/entity/model"
+)
+
+// deliver 向用户发送聊天消息，用户不在线时存入离线队列，返回消息是否已送达在线用户
+// 上线和投递都在事件循环中执行，不会有消息遗留在队列中
+func (h *Hub) deliver(name string, e model.Event) bool {
+	if client, ok := h.clients[name]; ok {
+		return h.send(client, e)
+	}
+	if h.offline == nil {
+		return false
+	}
+	if err := h.offline.Push(name, e); err != nil {
+		fmt.Printf("保存 %s 的离线消息失败: %v\n", name, err)
+	}
+	return false
+}
+
+// deliverGroup 向群组成员发送聊天消息，不在线的成员存入离线队列
+// 返回除发送者以外已送达的在线成员数
+func (h *Hub) deliverGroup(members []string, e model.Event) int {
+	n := 0
+	for _, memberName := range members {
+		if h.deliver(memberName, e) && memberName != e.Name {
+			n++
+		}
+	}
+	return n
+}
+
+// takeOffline 取出用户的离线消息
+func (h *Hub) takeOffline(name string) []model.Event {
+	if h.offline == nil {
+		return nil
+	}
+	events, err := h.offline.Take(name)
+	if err != nil {
+		fmt.Printf("读取 %s 的离线消息失败: %v\n", name, err)
+		return nil
+	}
+	return events
+}
+
+// flushOffline 按顺序投递离线消息，发送失败时剩余消息重新放回队列
+func (h *Hub) flushOffline(client model.Client, events []model.Event) {
+	for i, e := range events {
+		if err := client.Session.Send(e); err != nil {
+			fmt.Printf("投递离线消息给 %s 失败: %v\n", client.Name, err)
+			for _, rest := range events[i:] {
+				if err := h.offline.Push(client.Name, rest); err != nil {
+					fmt.Printf("保存 %s 的离线消息失败: %v\n", client.Name, err)
+				}
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package hub
+
+import (
+	"fmt"
+	"go-chatroom/pkg/entity/model"
+	"go-chatroom/pkg/enum"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	MaxReactionLength = 8  // 单个表情的最大字符数
+	MaxReactions      = 20 // 每条消息最多的不同表情数
+)
+
+// 用表情回应消息或取消回应，ID 为消息，Msg 为表情，能看到原消息的用户都可以回应
+// 回应汇总后保存到消息历史中，并通知在线的、能看到原消息的用户
+func (h *Hub) react(m model.Message, add bool) {
+	emoji := strings.TrimSpace(m.Msg)
+	if emoji == "" || utf8.RuneCountInString(emoji) > MaxReactionLength {
+		h.sendTo(m.Name, model.NewNotice(enum.ErrorEvent, m.Area, fmt.Sprintf("表情不能为空且不能超过 %d 个字符", MaxReactionLength)))
+		return
+	}
+	e, ok := h.findMessage(m)
+	if !ok {
+		return
+	}
+
+	users := e.Reactions[emoji]
+	i := indexOf(users, m.Name)
+	switch {
+	case add && i >= 0, !add && i < 0:
+		// 重复回应或取消不存在的回应时什么都不做
+		return
+	case add:
+		if len(users) == 0 && len(e.Reactions) >= MaxReactions {
+			h.sendTo(m.Name, model.NewNotice(enum.ErrorEvent, e.Area, fmt.Sprintf("每条消息最多 %d 种表情回应", MaxReactions)))
+			return
+		}
+		e.Reactions = cloneReactions(e.Reactions)
+		e.Reactions[emoji] = append(append([]string{}, users...), m.Name)
+	default:
+		e.Reactions = cloneReactions(e.Reactions)
+		rest := append(append([]string{}, users[:i]...), users[i+1:]...)
+		if len(rest) == 0 {
+			delete(e.Reactions, emoji)
+		} else {
+			e.Reactions[emoji] = rest
+		}
+	}
+	h.save(e)
+
+	event := model.NewNotice(enum.ReactionEvent, e.Area, emoji)
+	event.ID = e.ID
+	event.Name = m.Name
+	event.Target = e.Target
+	event.Group = e.Group
+	event.Reactions = e.Reactions
+	h.notify(e, event, false)
+}
+
+// cloneReactions 复制回应的键，历史中保存的记录可能被查询结果引用，不能原地修改
+func cloneReactions(reactions map[string][]string) map[string][]string {
+	clone := make(map[string][]string, len(reactions)+1)
+	for emoji, users := range reactions {
+		clone[emoji] = users
+	}
+	return clone
+}
+
+func indexOf(items []string, item string) int {
+	for i, v := range items {
+		if v == item {
+			return i
+		}
+	}
+	return -1
+}
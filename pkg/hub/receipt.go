@@ -0,0 +1,32 @@
+package hub
+
+import (
+	"go-chatroom/pkg/entity/model"
+	"go-chatroom/pkg/enum"
+)
+
+// 标记私聊消息已读，ID 为读者看到的最后一条消息，Target 为该消息的发送者
+// 发送者在线时收到已读回执，读者关闭了已读回执时忽略
+func (h *Hub) markRead(m model.Message) {
+	reader, exists := h.clients[m.Name]
+	if !exists || reader.User.HideReadReceipts {
+		return
+	}
+
+	// 只能标记别人发给自己的私聊消息，未启用历史时无法校验，不转发回执
+	if h.history == nil {
+		h.sendTo(m.Name, model.NewNotice(enum.ErrorEvent, enum.PrivateArea, "服务端未保存消息历史，无法发送已读回执"))
+		return
+	}
+	e, ok := h.history.Get(m.ID)
+	if !ok || e.Area != enum.PrivateArea || e.Name != m.Target || e.Target != m.Name {
+		h.sendTo(m.Name, model.NewNotice(enum.ErrorEvent, enum.PrivateArea, "只能将收到的私聊消息标记为已读"))
+		return
+	}
+
+	receipt := model.NewNotice(enum.ReadReceiptEvent, enum.PrivateArea, "")
+	receipt.ID = m.ID
+	receipt.Name = m.Name
+	receipt.Target = m.Target
+	h.sendTo(m.Target, receipt)
+}
@@ -0,0 +1,51 @@
+package hub
+
+import (
+	"errors"
+	"fmt"
+	"go-chatroom/pkg/entity/model"
+	"go-chatroom/pkg/enum"
+)
+
+// QuoteLength 回复时引用原消息的最大字数，超出的部分被截断
+const QuoteLength = 50
+
+// quote 校验回复的消息与 m 在同一个会话中，并返回引用的原消息，m 不是回复时返回空
+// m.Area 必须已经设置为实际的聊天区域
+func (h *Hub) quote(m model.Message) (*model.Quote, error) {
+	if m.ReplyTo == 0 {
+		return nil, nil
+	}
+	if h.history == nil {
+		return nil, errors.New("服务端未保存消息历史，无法回复消息")
+	}
+
+	parent, ok := h.history.Get(m.ReplyTo)
+	if !ok || !sameConversation(parent, m) || !h.visible(m.Name, parent) {
+		return nil, fmt.Errorf("消息 #%d 不在当前会话中", m.ReplyTo)
+	}
+	if parent.Deleted {
+		return nil, fmt.Errorf("消息 #%d 已被删除", m.ReplyTo)
+	}
+
+	msg := []rune(parent.Msg)
+	if len(msg) > QuoteLength {
+		msg = append(msg[:QuoteLength], []rune("…")...)
+	}
+	return &model.Quote{Name: parent.Name, Msg: string(msg)}, nil
+}
+
+// sameConversation 判断消息 e 是否与 m 属于同一个公屏、群聊或私聊会话
+func sameConversation(e model.Event, m model.Message) bool {
+	if e.Area != m.Area {
+		return false
+	}
+	switch m.Area {
+	case enum.GroupArea:
+		return e.Group == m.Group
+	case enum.PrivateArea:
+		return (e.Name == m.Name && e.Target == m.Target) || (e.Name == m.Target && e.Target == m.Name)
+	default:
+		return true
+	}
+}
@@ -0,0 +1,64 @@
+package hub
+
+import (
+	"go-chatroom/pkg/entity/model"
+	"go-chatroom/pkg/enum"
+	"time"
+)
+
+// TypingInterval 同一用户在同一会话中转发输入状态的最小间隔，期间重复的输入状态被丢弃
+// 客户端收到输入状态后应在数倍于该间隔的时间内没有新的输入状态时自动隐藏
+const TypingInterval = 2 * time.Second
+
+// typingKey 一个用户在一个私聊或群聊会话中的输入状态
+type typingKey struct {
+	name string
+	area enum.Area
+	peer string // 私聊对象或群组名称
+}
+
+// 转发输入状态给私聊对象或在线的群成员，只发给在线用户，不写日志也不保存
+func (h *Hub) typingNotice(m model.Message) {
+	var recipients []string
+	key := typingKey{name: m.Name, area: m.Area}
+	switch m.Area {
+	case enum.PrivateArea:
+		if m.Target == m.Name {
+			return
+		}
+		key.peer = m.Target
+		recipients = []string{m.Target}
+	case enum.GroupArea:
+		g, exists := h.groups[m.Group]
+		if !exists || !g.IsMember(m.Name) {
+			return
+		}
+		key.peer = m.Group
+		recipients = g.MemberNames()
+	default:
+		return
+	}
+
+	now := time.Now()
+	if last, ok := h.typing[key]; ok && now.Sub(last) < TypingInterval {
+		return
+	}
+	h.typing[key] = now
+
+	event := newEvent(enum.TypingEvent, m)
+	event.Msg = ""
+	for _, name := range recipients {
+		if name != m.Name {
+			h.sendTo(name, event)
+		}
+	}
+}
+
+// clearTyping 用户下线时清除其输入状态的转发记录
+func (h *Hub) clearTyping(name string) {
+	for key := range h.typing {
+		if key.name == name {
+			delete(h.typing, key)
+		}
+	}
+}
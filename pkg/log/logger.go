@@ -41,9 +41,20 @@ func (cl *ChatLogger) LogMessage(sender, receiver, group, msgType, content strin
 	}
 }
 
-// Close 关闭日志文件
+// Flush 将已写入的日志刷到磁盘
+func (cl *ChatLogger) Flush() error {
+	if cl.logFile == nil {
+		return nil
+	}
+	return cl.logFile.Sync()
+}
+
+// Close 刷盘后关闭日志文件
 func (cl *ChatLogger) Close() {
 	if cl.logFile != nil {
+		if err := cl.logFile.Sync(); err != nil {
+			fmt.Printf("日志刷盘失败: %v\n", err)
+		}
 		cl.logFile.Close()
 	}
 }
@@ -0,0 +1,211 @@
+package offline
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go-chatroom/pkg/entity/model"
+	"go-chatroom/pkg/fsutil"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+// MaxPending 每个用户最多保留的离线消息条数，超出时丢弃最早的消息
+const MaxPending = 1000
+
+// compactLines 文件行数超过该值且超过未投递消息数的两倍时重写文件
+const compactLines = 1000
+
+// entry 离线消息文件中的一行：为用户追加一条消息，或取出用户的全部消息
+type entry struct {
+	Name  string       `json:"name"`
+	Event *model.Event `json:"event,omitempty"`
+	Taken bool         `json:"taken,omitempty"`
+}
+
+// Queue 按用户保存未送达的消息，服务端重启后仍可投递
+// 每次变动只在 JSON 行文件末尾追加一行，已投递的行较多时才重写整个文件
+type Queue struct {
+	path    string
+	mu      sync.Mutex
+	file    *os.File
+	pending map[string][]model.Event
+	lines   int // 文件中的行数
+	count   int // 未投递的消息数
+}
+
+// NewQueue 从 path 加载离线消息，文件不存在时创建空队列
+func NewQueue(path string) (*Queue, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &Queue{
+		path:    path,
+		file:    file,
+		pending: make(map[string][]model.Event),
+	}
+	rewrite, err := q.load()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if rewrite {
+		if err := q.compact(); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return q, nil
+}
+
+// load 逐行重放离线消息文件，无法解析的行（例如写到一半时进程退出）会被跳过
+// 最后一行不完整时返回 true，需要重写文件
+func (q *Queue) load() (bool, error) {
+	var rewrite bool
+	reader := bufio.NewReader(q.file)
+	for lineNo := 1; ; lineNo++ {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			q.lines++
+			if err == io.EOF {
+				// 最后一行不完整，之后追加的内容会接在它后面
+				rewrite = true
+			}
+			var e entry
+			if jsonErr := json.Unmarshal(line, &e); jsonErr != nil {
+				fmt.Printf("跳过无法解析的离线消息 第%d行: %v\n", lineNo, jsonErr)
+			} else {
+				q.apply(e)
+			}
+		}
+		if err == io.EOF {
+			return rewrite, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+}
+
+// apply 在内存中执行文件中的一行
+func (q *Queue) apply(e entry) {
+	if e.Taken {
+		q.count -= len(q.pending[e.Name])
+		delete(q.pending, e.Name)
+		return
+	}
+	if e.Event != nil {
+		q.add(e.Name, *e.Event)
+	}
+}
+
+// add 在内存中为用户追加一条消息，超出上限时丢弃最早的消息
+func (q *Queue) add(name string, e model.Event) {
+	events := append(q.pending[name], e)
+	q.count++
+	if len(events) > MaxPending {
+		q.count -= len(events) - MaxPending
+		events = events[len(events)-MaxPending:]
+	}
+	q.pending[name] = events
+}
+
+// Push 为用户追加一条离线消息
+func (q *Queue) Push(name string, e model.Event) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if err := q.write(entry{Name: name, Event: &e}); err != nil {
+		return err
+	}
+	q.add(name, e)
+	q.maybeCompact()
+	return nil
+}
+
+// Take 取出并清空用户的全部离线消息，按入队顺序排列
+func (q *Queue) Take(name string) ([]model.Event, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	events, exists := q.pending[name]
+	if !exists {
+		return nil, nil
+	}
+	if err := q.write(entry{Name: name, Taken: true}); err != nil {
+		return nil, err
+	}
+	q.apply(entry{Name: name, Taken: true})
+	q.maybeCompact()
+	return events, nil
+}
+
+// Close 关闭离线消息文件
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.file.Close()
+}
+
+// write 在文件末尾追加一行，调用方需持有锁
+func (q *Queue) write(e entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := q.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	q.lines++
+	return nil
+}
+
+// maybeCompact 文件中大部分行已经没有用处时重写文件，重写的开销分摊到之前的每次追加上
+func (q *Queue) maybeCompact() {
+	if q.lines <= compactLines || q.lines <= 2*q.count {
+		return
+	}
+	if err := q.compact(); err != nil {
+		fmt.Printf("重写离线消息文件失败: %v\n", err)
+	}
+}
+
+// compact 只保留未投递的消息重写文件，调用方需持有锁
+func (q *Queue) compact() error {
+	names := make([]string, 0, len(q.pending))
+	for name := range q.pending {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		for i := range q.pending[name] {
+			data, err := json.Marshal(entry{Name: name, Event: &q.pending[name][i]})
+			if err != nil {
+				return err
+			}
+			buf.Write(data)
+			buf.WriteByte('\n')
+		}
+	}
+	if err := fsutil.WriteFileAtomic(q.path, buf.Bytes()); err != nil {
+		return err
+	}
+
+	// 原文件已被替换，之后需要追加到新文件中
+	file, err := os.OpenFile(q.path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	q.file.Close()
+	q.file = file
+	q.lines = q.count
+	return nil
+}
@@ -0,0 +1,89 @@
+package offline
+
+import (
+	"bytes"
+	"go-chatroom/pkg/entity/model"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func openQueue(t *testing.T, path string) *Queue {
+	t.Helper()
+	q, err := NewQueue(path)
+	if err != nil {
+		t.Fatalf("NewQueue: %v", err)
+	}
+	t.Cleanup(func() { q.Close() })
+	return q
+}
+
+func msgs(events []model.Event) []string {
+	var result []string
+	for _, e := range events {
+		result = append(result, e.Msg)
+	}
+	return result
+}
+
+func TestQueueReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "offline.json")
+	q := openQueue(t, path)
+	q.Push("bob", model.Event{Msg: "1"})
+	q.Push("carol", model.Event{Msg: "2"})
+	q.Push("bob", model.Event{Msg: "3"})
+	if events, err := q.Take("carol"); err != nil || len(events) != 1 {
+		t.Fatalf("Take = %v, %v", events, err)
+	}
+	q.Close()
+
+	q = openQueue(t, path)
+	if events, _ := q.Take("carol"); len(events) != 0 {
+		t.Fatalf("carol's messages were not removed: %v", msgs(events))
+	}
+	events, err := q.Take("bob")
+	if err != nil {
+		t.Fatalf("Take: %v", err)
+	}
+	if got := msgs(events); len(got) != 2 || got[0] != "1" || got[1] != "3" {
+		t.Fatalf("bob = %v, want [1 3]", got)
+	}
+}
+
+func TestQueueCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "offline.json")
+	q := openQueue(t, path)
+	for i := 0; i < 3*compactLines; i++ {
+		q.Push("bob", model.Event{Msg: "x"})
+		q.Take("bob")
+	}
+	q.Push("carol", model.Event{Msg: "kept"})
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(data, []byte("\n")); lines > 2*compactLines {
+		t.Fatalf("file has %d lines, want it compacted", lines)
+	}
+	q.Close()
+
+	q = openQueue(t, path)
+	if events, _ := q.Take("bob"); len(events) != 0 {
+		t.Fatalf("bob = %v, want none", msgs(events))
+	}
+	if events, _ := q.Take("carol"); len(events) != 1 || events[0].Msg != "kept" {
+		t.Fatalf("carol = %v, want [kept]", msgs(events))
+	}
+}
+
+func TestQueueMaxPending(t *testing.T) {
+	q := openQueue(t, filepath.Join(t.TempDir(), "offline.json"))
+	for i := 0; i < MaxPending+5; i++ {
+		q.Push("bob", model.Event{ID: uint64(i + 1)})
+	}
+	events, _ := q.Take("bob")
+	if len(events) != MaxPending || events[0].ID != 6 {
+		t.Fatalf("len = %d, first = %d, want %d messages starting at 6", len(events), events[0].ID, MaxPending)
+	}
+}
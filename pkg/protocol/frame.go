@@ -0,0 +1,119 @@
+package protocol
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// DefaultMaxFrameSize 默认单帧最大字节数
+const DefaultMaxFrameSize = 64 * 1024
+
+// ErrFrameTooLarge 帧长度超过上限，超出部分已被丢弃，后续帧仍可正常读取
+var ErrFrameTooLarge = errors.New("protocol: frame too large")
+
+// Encoder 按行写出 JSON 帧，每帧以 '\n' 结尾
+// 每次 Encode 只调用一次底层 Write，因此多个协程共享同一个 net.Conn 时帧不会交错
+type Encoder struct {
+	w io.Writer
+}
+
+// NewEncoder 创建一个写出到 w 的编码器
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode 将 v 序列化为 JSON 并作为一帧写出
+func (e *Encoder) Encode(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return e.WriteFrame(data)
+}
+
+// WriteFrame 写出一帧已经序列化好的数据，data 中不能包含换行符
+func (e *Encoder) WriteFrame(data []byte) error {
+	if bytes.IndexByte(data, '\n') >= 0 {
+		return errors.New("protocol: frame contains newline")
+	}
+	frame := make([]byte, 0, len(data)+1)
+	frame = append(frame, data...)
+	frame = append(frame, '\n')
+	_, err := e.w.Write(frame)
+	return err
+}
+
+// Decoder 从流中按行读取 JSON 帧
+type Decoder struct {
+	r        *bufio.Reader
+	maxFrame int
+}
+
+// NewDecoder 创建一个从 r 读取的解码器，maxFrameSize <= 0 时使用 DefaultMaxFrameSize
+func NewDecoder(r io.Reader, maxFrameSize int) *Decoder {
+	if maxFrameSize <= 0 {
+		maxFrameSize = DefaultMaxFrameSize
+	}
+	return &Decoder{
+		r:        bufio.NewReader(r),
+		maxFrame: maxFrameSize,
+	}
+}
+
+// ReadFrame 读取下一帧（不含结尾换行符），空行会被跳过
+// 帧超过上限时丢弃该帧剩余内容并返回 ErrFrameTooLarge
+func (d *Decoder) ReadFrame() ([]byte, error) {
+	for {
+		frame, err := d.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if len(frame) > 0 {
+			return frame, nil
+		}
+	}
+}
+
+// Decode 读取下一帧并反序列化到 v
+func (d *Decoder) Decode(v interface{}) error {
+	frame, err := d.ReadFrame()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(frame, v)
+}
+
+func (d *Decoder) readLine() ([]byte, error) {
+	var (
+		frame    []byte
+		tooLarge bool
+	)
+	for {
+		chunk, err := d.r.ReadSlice('\n')
+		if !tooLarge {
+			if len(frame)+len(chunk) > d.maxFrame+1 {
+				// 超出上限后只丢弃数据，不再累积
+				tooLarge = true
+				frame = nil
+			} else {
+				frame = append(frame, chunk...)
+			}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			if err == io.EOF && len(frame) > 0 && !tooLarge {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		if tooLarge {
+			return nil, ErrFrameTooLarge
+		}
+		return bytes.TrimRight(frame, "\r\n"), nil
+	}
+}
@@ -0,0 +1,118 @@
+package protocol
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// readAll 读出所有帧直到出错，返回读到的帧和最后的错误
+func readAll(d *Decoder) ([]string, error) {
+	var frames []string
+	for {
+		frame, err := d.ReadFrame()
+		if err != nil {
+			return frames, err
+		}
+		frames = append(frames, string(frame))
+	}
+}
+
+func TestReadFrameCoalesced(t *testing.T) {
+	d := NewDecoder(strings.NewReader("{\"a\":1}\n{\"b\":2}\n"), 0)
+	frames, err := readAll(d)
+	if err != io.EOF {
+		t.Fatalf("err = %v, want io.EOF", err)
+	}
+	want := []string{`{"a":1}`, `{"b":2}`}
+	if strings.Join(frames, "|") != strings.Join(want, "|") {
+		t.Fatalf("frames = %q, want %q", frames, want)
+	}
+}
+
+func TestReadFrameSplitAcrossReads(t *testing.T) {
+	// 超过 1KB 的帧，且每次 Read 只返回一个字节
+	long := `{"msg":"` + strings.Repeat("x", 4096) + `"}`
+	d := NewDecoder(iotest.OneByteReader(strings.NewReader(long+"\n")), 0)
+	frame, err := d.ReadFrame()
+	if err != nil {
+		t.Fatalf("ReadFrame: %v", err)
+	}
+	if string(frame) != long {
+		t.Fatalf("frame length = %d, want %d", len(frame), len(long))
+	}
+}
+
+func TestReadFrameTooLargeRecovers(t *testing.T) {
+	input := strings.Repeat("x", 100) + "\n" + `{"ok":true}` + "\n"
+	d := NewDecoder(strings.NewReader(input), 16)
+	if _, err := d.ReadFrame(); err != ErrFrameTooLarge {
+		t.Fatalf("err = %v, want ErrFrameTooLarge", err)
+	}
+	frame, err := d.ReadFrame()
+	if err != nil {
+		t.Fatalf("ReadFrame after oversized frame: %v", err)
+	}
+	if string(frame) != `{"ok":true}` {
+		t.Fatalf("frame = %q", frame)
+	}
+}
+
+func TestReadFrameLargerThanBuffer(t *testing.T) {
+	// 超过 bufio 默认缓冲区的超长帧也只丢弃该帧
+	input := strings.Repeat("x", 10000) + "\n" + `{}` + "\n"
+	d := NewDecoder(strings.NewReader(input), 1024)
+	if _, err := d.ReadFrame(); err != ErrFrameTooLarge {
+		t.Fatalf("err = %v, want ErrFrameTooLarge", err)
+	}
+	if frame, err := d.ReadFrame(); err != nil || string(frame) != `{}` {
+		t.Fatalf("frame = %q, err = %v", frame, err)
+	}
+}
+
+func TestReadFrameCRLF(t *testing.T) {
+	d := NewDecoder(strings.NewReader("{\"a\":1}\r\n\r\n{\"b\":2}\r\n"), 0)
+	frames, err := readAll(d)
+	if err != io.EOF {
+		t.Fatalf("err = %v, want io.EOF", err)
+	}
+	if len(frames) != 2 || frames[0] != `{"a":1}` || frames[1] != `{"b":2}` {
+		t.Fatalf("frames = %q", frames)
+	}
+}
+
+func TestReadFrameEOFWithoutNewline(t *testing.T) {
+	// 连接在帧中间断开时不能把残缺的帧当作完整的消息
+	d := NewDecoder(strings.NewReader("{\"a\":1}\n{\"b\":"), 0)
+	frames, err := readAll(d)
+	if err != io.ErrUnexpectedEOF {
+		t.Fatalf("err = %v, want io.ErrUnexpectedEOF", err)
+	}
+	if len(frames) != 1 || frames[0] != `{"a":1}` {
+		t.Fatalf("frames = %q", frames)
+	}
+}
+
+func TestEncoderRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	for _, v := range []map[string]int{{"a": 1}, {"b": 2}} {
+		if err := e.Encode(v); err != nil {
+			t.Fatalf("Encode: %v", err)
+		}
+	}
+	if err := e.WriteFrame([]byte("a\nb")); err == nil {
+		t.Fatal("WriteFrame accepted a frame containing a newline")
+	}
+
+	d := NewDecoder(&buf, 0)
+	var got map[string]int
+	if err := d.Decode(&got); err != nil || got["a"] != 1 {
+		t.Fatalf("Decode = %v, %v", got, err)
+	}
+	if err := d.Decode(&got); err != nil || got["b"] != 2 {
+		t.Fatalf("Decode = %v, %v", got, err)
+	}
+}
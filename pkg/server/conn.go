@@ -0,0 +1,148 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-chatroom/pkg/account"
+	"go-chatroom/pkg/entity/model"
+	"go-chatroom/pkg/enum"
+	"go-chatroom/pkg/protocol"
+	"go-chatroom/pkg/transport"
+	"strings"
+	"time"
+)
+
+// handle 处理一个客户端连接，TCP、TLS 与 WebSocket 连接共用同一套会话逻辑
+func (s *Server) handle(conn transport.Conn) {
+	defer conn.Close()
+
+	// 当前连接绑定的用户名，登录成功之后才会设置，此后忽略消息中的 Name 字段
+	var session string
+	defer func() {
+		// 未主动退出就断开的连接同样需要做下线处理
+		if session != "" {
+			s.hub.Unregister(session, conn)
+		}
+	}()
+
+	// 定期发送心跳，连接断开后停止
+	if s.cfg.Heartbeat > 0 {
+		stop := make(chan struct{})
+		defer close(stop)
+		go s.heartbeat(conn, stop)
+	}
+
+	for {
+		// 每次读取一个完整的帧，超过心跳超时时间没有收到任何消息则断开
+		s.extendDeadline(conn)
+		frame, err := conn.ReadFrame()
+		if err == protocol.ErrFrameTooLarge {
+			fmt.Printf("%v 消息超过 %d 字节上限，已丢弃\n", conn.RemoteAddr(), s.cfg.MaxFrameSize)
+			replyMsg := fmt.Sprintf("消息过大，超过 %d 字节的上限，已被丢弃", s.cfg.MaxFrameSize)
+			reply(conn, model.NewNotice(enum.ErrorEvent, enum.PublicScreen, replyMsg))
+			continue
+		}
+		if err != nil && isTimeout(err) {
+			fmt.Printf("%v 心跳超时，断开连接\n", conn.RemoteAddr())
+			return
+		}
+		if err != nil {
+			// Connection closed or error occurred
+			fmt.Printf("Connection closed or error: %v\n", err)
+			return
+		}
+
+		// 解析协议
+		var cMsg model.Message
+		err = json.Unmarshal(frame, &cMsg)
+		if err != nil {
+			fmt.Println("json.Unmarshal error:", err)
+			continue
+		}
+
+		// 设置时间戳
+		cMsg.Timestamp = time.Now().Unix()
+
+		// 心跳在登录前后都可以发送
+		switch cMsg.Op {
+		case enum.Pong:
+			// 收到任何消息都会刷新读取截止时间，无需其他处理
+			continue
+		case enum.Ping:
+			reply(conn, model.NewNotice(enum.PongEvent, enum.PublicScreen, ""))
+			continue
+		}
+
+		// 登录之前只接受注册和登录请求
+		if session == "" {
+			switch cMsg.Op {
+			case enum.Register:
+				s.register(conn, cMsg)
+			case enum.Login:
+				if err := s.login(conn, cMsg); err != nil {
+					fmt.Printf("%v 登录失败: %v\n", conn.RemoteAddr(), err)
+					reply(conn, model.NewNotice(enum.LoginRejectEvent, enum.PublicScreen, err.Error()))
+					continue
+				}
+				session = cMsg.Name
+			default:
+				reply(conn, model.NewNotice(enum.ErrorEvent, enum.PublicScreen, "请先登录"))
+			}
+			continue
+		}
+
+		// 已登录的连接一律使用会话中的身份
+		cMsg.Name = session
+
+		switch cMsg.Op {
+		case enum.Logout:
+			s.hub.Unregister(session, conn)
+			session = ""
+			return // Exit the handler when client logs out
+		case enum.Login, enum.Register:
+			reply(conn, model.NewNotice(enum.ErrorEvent, enum.PublicScreen, "当前连接已登录"))
+		default:
+			// 钩子未处理的消息交给聊天中心
+			if !s.dispatch(conn, cMsg) {
+				s.hub.Route(cMsg)
+			}
+		}
+	}
+}
+
+// register 注册新账号，Msg 为密码，注册成功后仍需登录
+func (s *Server) register(sess model.Session, m model.Message) {
+	err := s.accounts.Register(m.Name, m.Msg)
+	if err != nil {
+		fmt.Printf("%v 注册账号[%s]失败: %v\n", sess.RemoteAddr(), m.Name, err)
+		reply(sess, model.NewNotice(enum.RegisterRejectEvent, enum.PublicScreen, err.Error()))
+		return
+	}
+
+	fmt.Printf("%v 用户[%s]: 注册账号 \n", time.Now().Format("2006-01-02 15:04:05"), m.Name)
+
+	ack := model.NewNotice(enum.RegisterAckEvent, enum.PublicScreen, "")
+	ack.Name = m.Name
+	reply(sess, ack)
+}
+
+// login 校验账号密码后将用户名绑定到连接上，Msg 为密码，同名用户已在线时拒绝登录
+func (s *Server) login(sess model.Session, m model.Message) error {
+	if strings.TrimSpace(m.Name) == "" {
+		return account.ErrEmptyName
+	}
+
+	// 密码校验较慢，在连接自己的协程中完成，不占用聊天中心的事件循环
+	acc, err := s.accounts.Authenticate(m.Name, m.Msg)
+	if err != nil {
+		return err
+	}
+	return s.hub.Register(sess, m.Name, acc.User, m.LastID)
+}
+
+// reply 向尚未登记到聊天中心的连接直接返回事件
+func reply(sess model.Session, e model.Event) {
+	if err := sess.Send(e); err != nil {
+		fmt.Printf("向 %v 返回信息失败: %v\n", sess.RemoteAddr(), err)
+	}
+}
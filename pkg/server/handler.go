@@ -0,0 +1,42 @@
+package server
+
+import (
+	"go-chatroom/pkg/entity/model"
+	"go-chatroom/pkg/enum"
+)
+
+// Handler 消息处理钩子，在已登录用户的消息交给聊天中心之前调用
+// sess 为发送者的会话，m.Name 已经是会话绑定的用户名
+// 返回 true 表示消息已被处理，不再交给后续钩子和聊天中心
+type Handler func(sess model.Session, m model.Message) bool
+
+// anyOp 表示对所有操作生效的钩子
+const anyOp enum.Operation = 0
+
+type handlers map[enum.Operation][]Handler
+
+// Handle 为指定操作注册钩子，可以拦截内置操作，也可以处理自定义的操作
+// 钩子在连接协程中执行，应当在 Serve 之前注册
+func (s *Server) Handle(op enum.Operation, h Handler) {
+	s.handlers[op] = append(s.handlers[op], h)
+}
+
+// Use 注册对所有操作生效的钩子，先于 Handle 注册的钩子执行
+func (s *Server) Use(h Handler) {
+	s.handlers[anyOp] = append(s.handlers[anyOp], h)
+}
+
+// dispatch 依次执行钩子，返回消息是否已被处理
+func (s *Server) dispatch(sess model.Session, m model.Message) bool {
+	for _, h := range s.handlers[anyOp] {
+		if h(sess, m) {
+			return true
+		}
+	}
+	for _, h := range s.handlers[m.Op] {
+		if h(sess, m) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"go-chatroom/pkg/entity/model"
+	"go-chatroom/pkg/enum"
+	"go-chatroom/pkg/transport"
+	"net"
+	"time"
+)
+
+// heartbeat 定期向连接发送 ping 事件，直到 stop 被关闭
+func (s *Server) heartbeat(conn transport.Conn, stop <-chan struct{}) {
+	ticker := time.NewTicker(time.Duration(s.cfg.Heartbeat) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := conn.Send(model.NewNotice(enum.PingEvent, enum.PublicScreen, "")); err != nil {
+				return
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// extendDeadline 收到客户端的任何消息后重新计算读取截止时间
+// 不发送心跳时不检测超时：Web 页面只在收到 ping 后回复，空闲时不会发送任何消息
+func (s *Server) extendDeadline(conn transport.Conn) {
+	if s.cfg.Heartbeat <= 0 || s.cfg.HeartbeatTimeout <= 0 {
+		return
+	}
+	deadline := time.Now().Add(time.Duration(s.cfg.HeartbeatTimeout) * time.Second)
+	if err := conn.SetReadDeadline(deadline); err != nil {
+		fmt.Printf("设置 %v 的读取超时失败: %v\n", conn.RemoteAddr(), err)
+	}
+}
+
+// isTimeout 判断读取错误是否由心跳超时引起
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
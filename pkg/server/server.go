@@ -0,0 +1,314 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-chatroom/pkg/account"
+	"go-chatroom/pkg/config"
+	"go-chatroom/pkg/entity/model"
+	"go-chatroom/pkg/enum"
+	"go-chatroom/pkg/group"
+	"go-chatroom/pkg/history"
+	"go-chatroom/pkg/hub"
+	"go-chatroom/pkg/log"
+	"go-chatroom/pkg/offline"
+	"go-chatroom/pkg/tlsutil"
+	"go-chatroom/pkg/transport"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrServerClosed 服务已关闭，Serve 和 ListenAndServe 在 Shutdown 之后返回该错误
+var ErrServerClosed = errors.New("server: closed")
+
+// Server 聊天服务，可以嵌入到其他程序中运行
+type Server struct {
+	cfg          config.Server
+	queueOptions transport.QueueOptions // 每个客户端的发送队列参数
+
+	logger   *log.ChatLogger // 聊天日志记录器
+	accounts account.Store   // 账号存储
+	history  *history.Store  // 消息历史
+	offline  *offline.Queue  // 离线消息
+	groups   *group.Writer   // 群组有修改时在后台保存
+	hub      *hub.Hub        // 聊天中心，管理在线用户和群组
+
+	handlers handlers // 消息处理钩子
+
+	mu         sync.Mutex
+	listeners  map[net.Listener]struct{}
+	conns      map[transport.Conn]struct{}
+	httpServer *http.Server
+	closed     bool
+	wg         sync.WaitGroup // 正在运行的连接协程
+}
+
+// New 按配置加载账号、历史、离线消息和群组并启动聊天中心，此时还不会监听任何端口
+func New(cfg config.Server) (*Server, error) {
+	policy, err := transport.ParseOverflowPolicy(cfg.SlowClient)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MaxFrameSize <= 0 {
+		return nil, fmt.Errorf("max frame size must be positive, got %d", cfg.MaxFrameSize)
+	}
+	if cfg.Heartbeat > 0 && cfg.HeartbeatTimeout > 0 && cfg.HeartbeatTimeout <= cfg.Heartbeat {
+		// 客户端只在收到 ping 后回复，超时不长于心跳间隔时空闲的连接会被误判为断开
+		return nil, fmt.Errorf("heartbeat timeout %ds must be longer than the heartbeat interval %ds", cfg.HeartbeatTimeout, cfg.Heartbeat)
+	}
+
+	s := &Server{
+		cfg: cfg,
+		queueOptions: transport.QueueOptions{
+			Size:         cfg.SendQueue,
+			WriteTimeout: time.Duration(cfg.WriteTimeout) * time.Second,
+			Policy:       policy,
+		},
+		handlers:  make(handlers),
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[transport.Conn]struct{}),
+	}
+	if s.queueOptions.Size < offline.MaxPending {
+		// 登录时离线消息会一次性放入发送队列
+		fmt.Printf("警告：发送队列长度 %d 小于离线消息上限 %d，离线消息较多的用户登录时可能被断开或丢失消息\n", s.queueOptions.Size, offline.MaxPending)
+	}
+
+	// 初始化聊天日志记录器
+	s.logger, err = log.NewChatLogger(cfg.LogPath)
+	if err != nil {
+		return nil, fmt.Errorf("无法创建聊天日志文件: %w", err)
+	}
+
+	// 加载账号
+	s.accounts, err = account.NewFileStore(cfg.AccountsPath)
+	if err != nil {
+		s.logger.Close()
+		return nil, fmt.Errorf("无法加载账号文件: %w", err)
+	}
+
+	// 加载消息历史
+	s.history, err = history.Open(cfg.HistoryPath)
+	if err != nil {
+		s.logger.Close()
+		return nil, fmt.Errorf("无法加载消息历史文件: %w", err)
+	}
+
+	// 加载离线消息
+	s.offline, err = offline.NewQueue(cfg.OfflinePath)
+	if err != nil {
+		s.history.Close()
+		s.logger.Close()
+		return nil, fmt.Errorf("无法加载离线消息文件: %w", err)
+	}
+
+	// 恢复上次退出时保存的群组
+	groups, err := group.Load(cfg.GroupsPath)
+	if err != nil {
+		s.offline.Close()
+		s.history.Close()
+		s.logger.Close()
+		return nil, fmt.Errorf("无法加载群组文件: %w", err)
+	}
+
+	// 启动聊天中心，群组在每次修改后保存，异常退出时也不会丢失
+	s.groups = group.NewWriter(cfg.GroupsPath)
+	s.hub = hub.New(hub.Options{
+		Accounts:    s.accounts,
+		History:     s.history,
+		Offline:     s.offline,
+		Logger:      s.logger,
+		Groups:      groups,
+		GroupWriter: s.groups,
+	})
+	go s.hub.Run()
+
+	return s, nil
+}
+
+// Hub 返回聊天中心，供消息处理钩子向用户发送事件
+func (s *Server) Hub() *hub.Hub {
+	return s.hub
+}
+
+// ListenAndServe 监听配置的 TCP 地址，配置了 HTTPAddr 时同时提供 Web 页面和 WebSocket 接入
+func (s *Server) ListenAndServe() error {
+	// 启用 TLS 时所有连接都经过加密
+	listen, err := tlsutil.Listen(s.cfg.Addr, s.cfg.TLS)
+	if err != nil {
+		return err
+	}
+	if s.cfg.TLS.Enabled {
+		fmt.Println("聊天室开启成功！正在监听(TLS)", listen.Addr())
+	} else {
+		fmt.Println("聊天室开启成功！正在监听", listen.Addr())
+	}
+
+	// 浏览器通过 WebSocket 直接接入，与 TCP 客户端共享会话
+	if s.cfg.HTTPAddr != "" {
+		httpListen, err := tlsutil.Listen(s.cfg.HTTPAddr, s.cfg.TLS)
+		if err != nil {
+			listen.Close()
+			return fmt.Errorf("Web服务开启失败: %w", err)
+		}
+		fmt.Println("Web服务开启成功！正在监听", httpListen.Addr())
+		go func() {
+			if err := s.ServeHTTP(httpListen); err != nil && err != ErrServerClosed {
+				fmt.Printf("Web服务异常退出！error:%v\n", err)
+			}
+		}()
+	}
+
+	return s.Serve(listen)
+}
+
+// Serve 在给定的监听器上接受 TCP 连接，直到监听器出错或服务关闭
+func (s *Server) Serve(listen net.Listener) error {
+	if !s.trackListener(listen) {
+		listen.Close()
+		return ErrServerClosed
+	}
+	defer s.untrackListener(listen)
+
+	for {
+		// 当接收到连接请求时
+		conn, err := listen.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				fmt.Println("conn fail ...")
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+		// conn.RemoteAddr() 连接的客户端地址
+		fmt.Println(conn.RemoteAddr(), "connect successed")
+
+		// 为每一个客户端开单独的协程进行业务操作
+		s.serveConn(transport.NewStream(conn, s.cfg.MaxFrameSize))
+	}
+}
+
+// Shutdown 停止接受新连接，通知所有在线用户服务即将关闭，等待发送队列写完后断开连接，
+// 最后保存群组信息并关闭聊天中心和各个存储
+// ctx 到期时丢弃尚未写完的事件直接断开，并返回 ctx 的错误
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrServerClosed
+	}
+	s.closed = true
+	for listen := range s.listeners {
+		listen.Close()
+	}
+	httpServer := s.httpServer
+	s.mu.Unlock()
+
+	if httpServer != nil {
+		httpServer.Close()
+	}
+
+	// 通知在线用户并将其下线，不再向其他人广播下线消息
+	s.hub.Shutdown(model.NewNotice(enum.ShutdownEvent, enum.PublicScreen, "服务器正在关闭，请稍后重新连接"))
+
+	// 关闭连接时会先写完发送队列，连接协程随之退出
+	s.mu.Lock()
+	conns := make([]transport.Conn, 0, len(s.conns))
+	for conn := range s.conns {
+		conns = append(conns, conn)
+	}
+	s.mu.Unlock()
+	for _, conn := range conns {
+		go conn.Close()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+		fmt.Printf("等待发送队列写完超时，直接断开 %d 个连接\n", len(conns))
+		for _, conn := range conns {
+			if a, ok := conn.(aborter); ok {
+				a.Abort()
+			}
+		}
+		<-done
+	}
+
+	// 离线消息和群组在每次变动时已经保存，这里只需等待群组写完
+	s.hub.Stop()
+	s.groups.Close()
+	if err := s.offline.Close(); err != nil {
+		fmt.Printf("关闭离线消息文件失败: %v\n", err)
+	}
+	if err := s.history.Close(); err != nil {
+		fmt.Printf("关闭消息历史文件失败: %v\n", err)
+	}
+	s.logger.Close()
+	return err
+}
+
+// aborter 支持丢弃发送队列立即断开的连接
+type aborter interface {
+	Abort() error
+}
+
+// serveConn 为连接加上发送队列并在单独的协程中处理，服务关闭后直接断开
+func (s *Server) serveConn(conn transport.Conn) {
+	queued := transport.NewQueued(conn, s.queueOptions)
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		queued.Close()
+		return
+	}
+	s.conns[queued] = struct{}{}
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	go func() {
+		defer s.wg.Done()
+		defer func() {
+			s.mu.Lock()
+			delete(s.conns, queued)
+			s.mu.Unlock()
+		}()
+		s.handle(queued)
+	}()
+}
+
+func (s *Server) trackListener(listen net.Listener) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.listeners[listen] = struct{}{}
+	return true
+}
+
+func (s *Server) untrackListener(listen net.Listener) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.listeners, listen)
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
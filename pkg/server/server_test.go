@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"go-chatroom/pkg/client"
+	"go-chatroom/pkg/config"
+	"go-chatroom/pkg/entity/model"
+	"go-chatroom/pkg/enum"
+	"go-chatroom/pkg/transport"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testConfig(t *testing.T) config.Server {
+	dir := t.TempDir()
+	cfg := config.Default().Server
+	cfg.LogPath = filepath.Join(dir, "chat.log")
+	cfg.AccountsPath = filepath.Join(dir, "accounts.json")
+	cfg.HistoryPath = filepath.Join(dir, "history.jsonl")
+	cfg.OfflinePath = filepath.Join(dir, "offline.json")
+	cfg.GroupsPath = filepath.Join(dir, "groups.json")
+	cfg.HTTPAddr = ""
+	return cfg
+}
+
+// startServer 在随机端口上启动服务端，返回监听地址和 Serve 的返回值
+func startServer(t *testing.T) (*Server, string, <-chan error) {
+	t.Helper()
+	s, err := New(testConfig(t))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(listen) }()
+	return s, listen.Addr().String(), served
+}
+
+// login 注册并登录一个用户
+func login(t *testing.T, addr, name string) *client.Client {
+	t.Helper()
+	c, err := client.Connect(addr, config.TLS{})
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	if err := c.Register(name, "secret"); err != nil {
+		t.Fatalf("Register(%s): %v", name, err)
+	}
+	if _, err := c.Login(name, "secret"); err != nil {
+		t.Fatalf("Login(%s): %v", name, err)
+	}
+	return c
+}
+
+// waitEvent 跳过其他事件，等待指定类型的事件
+func waitEvent(t *testing.T, c *client.Client, kind enum.EventKind) model.Event {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e, ok := <-c.Events():
+			if !ok {
+				t.Fatalf("%s: connection closed while waiting for %s: %v", c.Name(), kind, c.Err())
+			}
+			if e.Kind == kind {
+				return e
+			}
+		case <-timeout:
+			t.Fatalf("%s: timed out waiting for %s", c.Name(), kind)
+		}
+	}
+}
+
+func TestServeAndShutdown(t *testing.T) {
+	s, addr, served := startServer(t)
+	alice := login(t, addr, "alice")
+	bob := login(t, addr, "bob")
+
+	if _, err := alice.Whisper("bob", "hello"); err != nil {
+		t.Fatalf("Whisper: %v", err)
+	}
+	e := waitEvent(t, bob, enum.ChatEvent)
+	if e.Name != "alice" || e.Msg != "hello" || e.Area != enum.PrivateArea {
+		t.Fatalf("bob got %+v, want alice's private message", e)
+	}
+	if ack := waitEvent(t, alice, enum.AckEvent); ack.Status != enum.StatusDelivered {
+		t.Fatalf("ack status = %s, want %s", ack.Status, enum.StatusDelivered)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	for _, c := range []*client.Client{alice, bob} {
+		waitEvent(t, c, enum.ShutdownEvent)
+		select {
+		case <-c.Done():
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s was not disconnected", c.Name())
+		}
+	}
+	if err := <-served; err != ErrServerClosed {
+		t.Fatalf("Serve = %v, want ErrServerClosed", err)
+	}
+}
+
+func TestHeartbeatTimeoutConfig(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Heartbeat, cfg.HeartbeatTimeout = 30, 30
+	if _, err := New(cfg); err == nil {
+		t.Fatal("accepted a heartbeat timeout no longer than the interval")
+	}
+
+	// 不发送心跳时不设置读取超时
+	cfg.Heartbeat = 0
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer s.Shutdown(context.Background())
+	conn := &deadlineConn{}
+	s.extendDeadline(conn)
+	if !conn.deadline.IsZero() {
+		t.Fatalf("read deadline set to %v with heartbeat disabled", conn.deadline)
+	}
+}
+
+// deadlineConn 只记录读取截止时间
+type deadlineConn struct {
+	transport.Conn
+	deadline time.Time
+}
+
+func (c *deadlineConn) SetReadDeadline(t time.Time) error {
+	c.deadline = t
+	return nil
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"fmt"
+	"go-chatroom/pkg/transport"
+	"net"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+)
+
+// 默认只接受与页面同源的 WebSocket 请求，页面由本服务直接提供
+var upgrader = websocket.Upgrader{}
+
+// ServeHTTP 在给定的监听器上提供 Web 页面和 /ws 接入点，直到服务关闭
+func (s *Server) ServeHTTP(listen net.Listener) error {
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.Dir(s.cfg.WebDir)))
+	mux.HandleFunc("/ws", s.HandleWebSocket)
+
+	httpServer := &http.Server{Handler: mux}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		listen.Close()
+		return ErrServerClosed
+	}
+	s.httpServer = httpServer
+	s.mu.Unlock()
+
+	err := httpServer.Serve(listen)
+	if err == http.ErrServerClosed {
+		return ErrServerClosed
+	}
+	return err
+}
+
+// HandleWebSocket 升级为 WebSocket 后按普通连接处理，可以挂载到调用方自己的路由上
+func (s *Server) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		fmt.Printf("WebSocket升级失败: %v\n", err)
+		return
+	}
+	fmt.Println(ws.RemoteAddr(), "websocket connect successed")
+
+	s.serveConn(transport.NewWebSocket(ws, s.cfg.MaxFrameSize))
+}
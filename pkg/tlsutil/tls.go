@@ -0,0 +1,97 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"go-chatroom/pkg/config"
+	"net"
+	"os"
+)
+
+// ServerConfig 根据配置构造服务端 TLS 配置，设置 CAFile 时要求并校验客户端证书
+func ServerConfig(c config.TLS) (*tls.Config, error) {
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, errors.New("启用 TLS 时必须指定证书和私钥文件")
+	}
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("加载证书失败: %v", err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if c.CAFile != "" {
+		pool, err := loadCertPool(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// ClientConfig 根据配置构造客户端 TLS 配置
+func ClientConfig(c config.TLS) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+	if c.CAFile != "" {
+		pool, err := loadCertPool(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("加载客户端证书失败: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// Listen 在 addr 上监听 tcp 连接，启用 TLS 时返回 TLS 监听器
+func Listen(addr string, c config.TLS) (net.Listener, error) {
+	if !c.Enabled {
+		return net.Listen("tcp", addr)
+	}
+	tlsConfig, err := ServerConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	return tls.Listen("tcp", addr, tlsConfig)
+}
+
+// Dial 连接 addr，启用 TLS 时完成握手后返回
+func Dial(addr string, c config.TLS) (net.Conn, error) {
+	if !c.Enabled {
+		return net.Dial("tcp", addr)
+	}
+	tlsConfig, err := ClientConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	return tls.Dial("tcp", addr, tlsConfig)
+}
+
+// loadCertPool 从 PEM 文件加载证书池
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取 CA 文件失败: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("CA 文件 %s 中没有有效的证书", path)
+	}
+	return pool, nil
+}
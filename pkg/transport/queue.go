@@ -0,0 +1,198 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"go-chatroom/pkg/entity/model"
+	"sync"
+	"time"
+)
+
+// OverflowPolicy 发送队列已满（客户端接收过慢）时的处理策略
+type OverflowPolicy string
+
+const (
+	DropOldest OverflowPolicy = "drop_oldest" // 丢弃队列中最早的事件
+	Disconnect OverflowPolicy = "disconnect"  // 断开该客户端
+)
+
+// ErrSlowClient 客户端接收过慢，发送队列已满，连接已被断开
+var ErrSlowClient = errors.New("transport: client too slow, disconnected")
+
+// ErrClosed 连接已关闭
+var ErrClosed = errors.New("transport: connection closed")
+
+// ParseOverflowPolicy 解析配置中的队列溢出策略
+func ParseOverflowPolicy(s string) (OverflowPolicy, error) {
+	switch p := OverflowPolicy(s); p {
+	case DropOldest, Disconnect:
+		return p, nil
+	default:
+		return "", fmt.Errorf("未知的慢客户端处理策略 %q，可选 %s 或 %s", s, DropOldest, Disconnect)
+	}
+}
+
+// DefaultDrainTimeout Close 等待发送队列写完的默认时间
+const DefaultDrainTimeout = 10 * time.Second
+
+// QueueOptions 发送队列参数
+type QueueOptions struct {
+	Size         int            // 队列长度
+	WriteTimeout time.Duration  // 单次写出的超时时间，0 表示不限制
+	Policy       OverflowPolicy // 队列已满时的处理策略
+	// DrainTimeout Close 等待队列写完的最长时间，超时后丢弃剩余事件直接断开，0 表示使用 DefaultDrainTimeout
+	// 不设置写超时时，不再读取的客户端也不会让 Close 一直阻塞
+	DrainTimeout time.Duration
+}
+
+// deadliner 支持设置写超时的连接
+type deadliner interface {
+	SetWriteDeadline(t time.Time) error
+}
+
+// queuedConn 为连接增加有界发送队列和独立的写协程，Send 不会因为客户端接收过慢而阻塞
+type queuedConn struct {
+	Conn
+	opts QueueOptions
+
+	queue   chan model.Event
+	mu      sync.Mutex // 保护 closed，并保证丢弃最早事件与入队不会交错
+	closed  bool
+	done    chan struct{} // 关闭时通知写协程
+	stopped chan struct{} // 写协程退出后关闭
+}
+
+// NewQueued 为 conn 创建发送队列并启动写协程
+func NewQueued(conn Conn, opts QueueOptions) Conn {
+	if opts.Size <= 0 {
+		opts.Size = 1
+	}
+	if opts.Policy == "" {
+		opts.Policy = Disconnect
+	}
+	if opts.DrainTimeout <= 0 {
+		opts.DrainTimeout = DefaultDrainTimeout
+	}
+	c := &queuedConn{
+		Conn:    conn,
+		opts:    opts,
+		queue:   make(chan model.Event, opts.Size),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go c.writeLoop()
+	return c
+}
+
+// Send 将事件放入发送队列，队列已满时按策略丢弃最早的事件或断开连接
+func (c *queuedConn) Send(e model.Event) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return ErrClosed
+	}
+	select {
+	case c.queue <- e:
+		return nil
+	default:
+	}
+
+	if c.opts.Policy == DropOldest {
+		select {
+		case <-c.queue:
+		default:
+		}
+		select {
+		case c.queue <- e:
+		default:
+		}
+		return nil
+	}
+
+	// 直接关闭底层连接，正在阻塞的写操作会立即返回，不再发送队列中剩余的事件
+	c.closeLocked()
+	c.Conn.Close()
+	return ErrSlowClient
+}
+
+// Close 发送完队列中剩余的事件后关闭连接，超过 DrainTimeout 仍未写完时按 Abort 处理
+func (c *queuedConn) Close() error {
+	c.mu.Lock()
+	c.closeLocked()
+	c.mu.Unlock()
+
+	timer := time.NewTimer(c.opts.DrainTimeout)
+	defer timer.Stop()
+	select {
+	case <-c.stopped:
+		return nil
+	case <-timer.C:
+		return c.Abort()
+	}
+}
+
+// Abort 丢弃队列中剩余的事件并立即关闭连接
+func (c *queuedConn) Abort() error {
+	c.mu.Lock()
+	c.closeLocked()
+	c.mu.Unlock()
+
+	// 关闭底层连接后正在阻塞的写操作会立即返回
+	err := c.Conn.Close()
+	<-c.stopped
+	return err
+}
+
+// closeLocked 标记连接已关闭并通知写协程，调用方需持有 mu
+func (c *queuedConn) closeLocked() {
+	if !c.closed {
+		c.closed = true
+		close(c.done)
+	}
+}
+
+// writeLoop 写协程，依次写出队列中的事件，写出失败时关闭连接
+func (c *queuedConn) writeLoop() {
+	defer close(c.stopped)
+	defer c.Conn.Close()
+
+	for {
+		select {
+		case e := <-c.queue:
+			if err := c.write(e); err != nil {
+				c.fail()
+				return
+			}
+		case <-c.done:
+			for {
+				select {
+				case e := <-c.queue:
+					if err := c.write(e); err != nil {
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// write 带超时地写出一个事件
+func (c *queuedConn) write(e model.Event) error {
+	if d, ok := c.Conn.(deadliner); ok && c.opts.WriteTimeout > 0 {
+		if err := d.SetWriteDeadline(time.Now().Add(c.opts.WriteTimeout)); err != nil {
+			return err
+		}
+	}
+	return c.Conn.Send(e)
+}
+
+// fail 写出失败后标记连接已关闭，之后的 Send 直接返回错误
+func (c *queuedConn) fail() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closeLocked()
+}
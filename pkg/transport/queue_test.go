@@ -0,0 +1,201 @@
+package transport
+
+import (
+	"errors"
+	"go-chatroom/pkg/entity/model"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeConn 记录写出的事件，gated 为 true 时每次写出都要等 release，用来模拟接收缓慢的客户端
+type fakeConn struct {
+	gated   bool
+	writing chan uint64   // 开始写出某个事件时通知测试
+	release chan struct{} // 放行一次写出
+	closed  chan struct{}
+
+	mu        sync.Mutex
+	sent      []uint64
+	closeOnce sync.Once
+}
+
+func newFakeConn(gated bool) *fakeConn {
+	return &fakeConn{
+		gated:   gated,
+		writing: make(chan uint64, 16),
+		release: make(chan struct{}),
+		closed:  make(chan struct{}),
+	}
+}
+
+func (c *fakeConn) Send(e model.Event) error {
+	if c.gated {
+		c.writing <- e.ID
+		select {
+		case <-c.release:
+		case <-c.closed:
+			return ErrClosed
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sent = append(c.sent, e.ID)
+	return nil
+}
+
+func (c *fakeConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return nil
+}
+
+func (c *fakeConn) isClosed() bool {
+	select {
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *fakeConn) written() []uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]uint64(nil), c.sent...)
+}
+
+func (c *fakeConn) ID() string                        { return "fake" }
+func (c *fakeConn) RemoteAddr() net.Addr              { return &net.TCPAddr{} }
+func (c *fakeConn) ReadFrame() ([]byte, error)        { return nil, errors.New("not implemented") }
+func (c *fakeConn) SetReadDeadline(t time.Time) error { return nil }
+
+// waitWriting 等待写协程开始写出指定的事件，此时该事件已经离开队列
+func waitWriting(t *testing.T, c *fakeConn, id uint64) {
+	t.Helper()
+	select {
+	case got := <-c.writing:
+		if got != id {
+			t.Fatalf("writing %d, want %d", got, id)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("event %d was not written", id)
+	}
+}
+
+func equal(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDropOldest(t *testing.T) {
+	fake := newFakeConn(true)
+	conn := NewQueued(fake, QueueOptions{Size: 2, Policy: DropOldest})
+
+	conn.Send(model.Event{ID: 1})
+	waitWriting(t, fake, 1)
+	for id := uint64(2); id <= 4; id++ {
+		if err := conn.Send(model.Event{ID: id}); err != nil {
+			t.Fatalf("Send(%d): %v", id, err)
+		}
+	}
+
+	// 队列中为 3、4，最早的 2 被丢弃
+	go func() {
+		for range []int{1, 3, 4} {
+			fake.release <- struct{}{}
+		}
+	}()
+	conn.Close()
+	if got := fake.written(); !equal(got, []uint64{1, 3, 4}) {
+		t.Fatalf("written = %v, want [1 3 4]", got)
+	}
+}
+
+func TestDisconnectSlowClient(t *testing.T) {
+	fake := newFakeConn(true)
+	conn := NewQueued(fake, QueueOptions{Size: 1, Policy: Disconnect})
+
+	conn.Send(model.Event{ID: 1})
+	waitWriting(t, fake, 1)
+	if err := conn.Send(model.Event{ID: 2}); err != nil {
+		t.Fatalf("Send(2): %v", err)
+	}
+	if err := conn.Send(model.Event{ID: 3}); err != ErrSlowClient {
+		t.Fatalf("err = %v, want ErrSlowClient", err)
+	}
+	if !fake.isClosed() {
+		t.Fatal("underlying connection not closed")
+	}
+	if err := conn.Send(model.Event{ID: 4}); err != ErrClosed {
+		t.Fatalf("err = %v, want ErrClosed", err)
+	}
+	conn.Close()
+	if got := fake.written(); len(got) != 0 {
+		t.Fatalf("written = %v, want nothing after disconnect", got)
+	}
+}
+
+func TestCloseDrainsQueue(t *testing.T) {
+	fake := newFakeConn(false)
+	conn := NewQueued(fake, QueueOptions{Size: 8})
+	for id := uint64(1); id <= 5; id++ {
+		conn.Send(model.Event{ID: id})
+	}
+	conn.Close()
+
+	if got := fake.written(); !equal(got, []uint64{1, 2, 3, 4, 5}) {
+		t.Fatalf("written = %v, want [1 2 3 4 5]", got)
+	}
+	if !fake.isClosed() {
+		t.Fatal("underlying connection not closed")
+	}
+	if err := conn.Send(model.Event{ID: 6}); err != ErrClosed {
+		t.Fatalf("err = %v, want ErrClosed", err)
+	}
+}
+
+func TestAbortDiscardsQueue(t *testing.T) {
+	fake := newFakeConn(true)
+	conn := NewQueued(fake, QueueOptions{Size: 8})
+	conn.Send(model.Event{ID: 1})
+	waitWriting(t, fake, 1)
+	conn.Send(model.Event{ID: 2})
+
+	conn.(*queuedConn).Abort()
+	if got := fake.written(); len(got) != 0 {
+		t.Fatalf("written = %v, want nothing", got)
+	}
+	if !fake.isClosed() {
+		t.Fatal("underlying connection not closed")
+	}
+}
+
+func TestCloseStuckClient(t *testing.T) {
+	// 不设置写超时，客户端不再读取时 Close 也会在 DrainTimeout 后返回
+	fake := newFakeConn(true)
+	conn := NewQueued(fake, QueueOptions{Size: 8, DrainTimeout: 50 * time.Millisecond})
+	conn.Send(model.Event{ID: 1})
+	waitWriting(t, fake, 1)
+
+	done := make(chan struct{})
+	go func() {
+		conn.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Close blocked on a client that stopped reading")
+	}
+	if !fake.isClosed() {
+		t.Fatal("underlying connection not closed")
+	}
+}
@@ -0,0 +1,59 @@
+package transport
+
+import (
+	"go-chatroom/pkg/entity/model"
+	"go-chatroom/pkg/protocol"
+	"net"
+	"sync"
+	"time"
+)
+
+// streamConn 基于字节流的连接，适用于 TCP、TLS、Unix socket 以及 net.Pipe 等任何 net.Conn
+type streamConn struct {
+	id      string
+	conn    net.Conn
+	dec     *protocol.Decoder
+	enc     *protocol.Encoder
+	writeMu sync.Mutex // 保证并发发送时帧不会交错
+}
+
+// NewStream 将 net.Conn 包装为使用按行分隔 JSON 帧的连接，maxFrameSize <= 0 时使用默认上限
+func NewStream(conn net.Conn, maxFrameSize int) Conn {
+	return &streamConn{
+		id:   newID(conn.LocalAddr().Network()),
+		conn: conn,
+		dec:  protocol.NewDecoder(conn, maxFrameSize),
+		enc:  protocol.NewEncoder(conn),
+	}
+}
+
+func (c *streamConn) ID() string {
+	return c.id
+}
+
+func (c *streamConn) ReadFrame() ([]byte, error) {
+	return c.dec.ReadFrame()
+}
+
+func (c *streamConn) Send(e model.Event) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	return c.enc.Encode(e)
+}
+
+func (c *streamConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *streamConn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+func (c *streamConn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+func (c *streamConn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
@@ -0,0 +1,25 @@
+package transport
+
+import (
+	"fmt"
+	"go-chatroom/pkg/entity/model"
+	"sync/atomic"
+	"time"
+)
+
+// Conn 服务端使用的客户端连接，在 model.Session 的基础上提供读取客户端帧的能力
+type Conn interface {
+	model.Session
+	// ReadFrame 读取客户端发来的下一帧，帧超过上限时返回 protocol.ErrFrameTooLarge，之后仍可继续读取
+	ReadFrame() ([]byte, error)
+	// SetReadDeadline 设置读取的截止时间，超时后 ReadFrame 返回错误，零值表示不限制
+	SetReadDeadline(t time.Time) error
+}
+
+// sessionSeq 连接序号，用于生成连接ID
+var sessionSeq uint64
+
+// newID 生成形如 tcp-1、ws-2 的连接ID
+func newID(kind string) string {
+	return fmt.Sprintf("%s-%d", kind, atomic.AddUint64(&sessionSeq, 1))
+}
@@ -0,0 +1,86 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"go-chatroom/pkg/entity/model"
+	"go-chatroom/pkg/protocol"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsConn 基于 WebSocket 的连接，每条文本消息对应协议中的一帧
+type wsConn struct {
+	id       string
+	ws       *websocket.Conn
+	maxFrame int
+	writeMu  sync.Mutex // WebSocket 不支持并发写
+}
+
+// NewWebSocket 将已完成升级的 WebSocket 连接包装为连接，maxFrameSize <= 0 时使用默认上限
+func NewWebSocket(ws *websocket.Conn, maxFrameSize int) Conn {
+	if maxFrameSize <= 0 {
+		maxFrameSize = protocol.DefaultMaxFrameSize
+	}
+	return &wsConn{
+		id:       newID("ws"),
+		ws:       ws,
+		maxFrame: maxFrameSize,
+	}
+}
+
+func (c *wsConn) ID() string {
+	return c.id
+}
+
+// ReadFrame 读取下一条消息，空消息会被跳过，超过上限的消息剩余部分在读取下一条时自动丢弃
+func (c *wsConn) ReadFrame() ([]byte, error) {
+	for {
+		_, r, err := c.ws.NextReader()
+		if err != nil {
+			return nil, err
+		}
+		frame, err := io.ReadAll(io.LimitReader(r, int64(c.maxFrame)+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(frame) > c.maxFrame {
+			return nil, protocol.ErrFrameTooLarge
+		}
+		if len(bytes.TrimSpace(frame)) > 0 {
+			return frame, nil
+		}
+	}
+}
+
+func (c *wsConn) Send(e model.Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	return c.ws.WriteMessage(websocket.TextMessage, data)
+}
+
+func (c *wsConn) Close() error {
+	return c.ws.Close()
+}
+
+func (c *wsConn) RemoteAddr() net.Addr {
+	return c.ws.RemoteAddr()
+}
+
+func (c *wsConn) SetReadDeadline(t time.Time) error {
+	return c.ws.SetReadDeadline(t)
+}
+
+func (c *wsConn) SetWriteDeadline(t time.Time) error {
+	return c.ws.SetWriteDeadline(t)
+}
@@ -6,49 +6,29 @@ echo "=========================="
 # 获取当前脚本所在目录
 SCRIPT_DIR=$(cd "$(dirname "${BASH_SOURCE[0]}")" && pwd)
 
-# 检查TCP服务器是否在运行
-if lsof -i :8000 | grep LISTEN > /dev/null; then
-    echo "✓ TCP聊天服务器已在端口8000运行"
-else
-    echo "⚠ TCP聊天服务器未运行"
-    read -p "是否启动TCP聊天服务器? (y/n): " -n 1 -r
-    echo
-    if [[ $REPLY =~ ^[Yy]$ ]]; then
-        echo "启动TCP聊天服务器..."
-        cd "$SCRIPT_DIR/server"
-        go run server.go &
-        SERVER_PID=$!
-        cd "$SCRIPT_DIR"
-        echo "TCP服务器启动，PID: $SERVER_PID"
-
-        # 等待服务器启动
-        sleep 2
-    fi
-fi
-
-# 检查Web API服务器是否在运行
+# 聊天服务器同时提供TCP(8000)和Web(8080)接入
 if lsof -i :8080 | grep LISTEN > /dev/null; then
-    echo "⚠ Web API服务器已在端口8080运行"
+    echo "⚠ 端口8080已被占用"
     read -p "是否关闭现有服务并重启? (y/n): " -n 1 -r
     echo
     if [[ $REPLY =~ ^[Yy]$ ]]; then
         lsof -ti:8080 | xargs kill -9 2>/dev/null
-        echo "关闭现有Web API服务器"
+        echo "关闭现有服务"
     else
-        echo "使用现有的Web API服务器"
+        echo "使用现有的服务"
         echo "打开浏览器访问 http://localhost:8080"
         open http://localhost:8080
         exit 0
     fi
 fi
 
-echo "启动Web API服务器..."
-cd "$SCRIPT_DIR/api"
-go run main.go &
-API_PID=$!
-echo "Web API服务器启动，PID: $API_PID"
+echo "启动聊天服务器..."
+cd "$SCRIPT_DIR/server"
+go run . &
+SERVER_PID=$!
+echo "聊天服务器启动，PID: $SERVER_PID"
 
-# 等待API服务器启动
+# 等待服务器启动
 sleep 2
 
 echo "打开浏览器访问 http://localhost:8080"
@@ -56,4 +36,4 @@ cd "$SCRIPT_DIR"
 open http://localhost:8080
 
 # 等待进程
-wait $API_PID
\ No newline at end of file
+wait $SERVER_PID
@@ -1,9 +1,25 @@
 // 聊天室Web客户端
+
+const TYPING_INTERVAL = 2000; // 发送输入状态的间隔(毫秒)，与服务端的 hub.TypingInterval 一致
+const TYPING_TIMEOUT = 6000;  // 超过该时间没有收到新的输入状态则隐藏(毫秒)
+const REACTION_EMOJIS = ['👍', '❤️', '😂', '🎉', '😮', '😢']; // 表情回应的候选表情
+
 class ChatClient {
     constructor() {
         this.ws = null;
         this.currentUser = '';
         this.currentTab = 'public';
+        this.loggedIn = false;
+        this.oldestIds = {};      // 每个会话已加载的最早消息ID，用于向前翻页
+        this.seenIds = new Set(); // 已显示的消息ID，避免历史消息重复显示
+        this.correlationSeq = 0;  // 最后生成的消息标识，服务端在投递结果中原样返回
+        this.profile = {};        // 当前用户的资料和设置
+        this.unread = {};         // 每个私聊对象发来的、尚未回执的最后一条消息ID
+        this.readUpTo = {};       // 每个私聊对象已经回执到的消息ID
+        this.typingUsers = {};    // 正在输入的用户，键为会话，值为用户名和隐藏定时器
+        this.lastTypingSent = 0;  // 最后一次发送输入状态的时间
+        this.groupRoles = {};     // 各群组的成员角色，用于判断能否删除他人的消息
+        this.replyTo = null;      // 正在回复的消息
         this.users = [];
         this.groups = [];
         
@@ -17,12 +33,17 @@ class ChatClient {
         document.getElementById('username').addEventListener('keypress', (e) => {
             if (e.key === 'Enter') this.login();
         });
+        document.getElementById('password').addEventListener('keypress', (e) => {
+            if (e.key === 'Enter') this.login();
+        });
+        document.getElementById('register-btn').addEventListener('click', () => this.register());
 
         // 聊天界面事件
         document.getElementById('send-btn').addEventListener('click', () => this.sendMessage());
         document.getElementById('message-input').addEventListener('keypress', (e) => {
             if (e.key === 'Enter') this.sendMessage();
         });
+        document.getElementById('message-input').addEventListener('input', () => this.sendTyping());
 
         // 退出事件
         document.getElementById('logout-btn').addEventListener('click', () => this.logout());
@@ -32,16 +53,37 @@ class ChatClient {
             btn.addEventListener('click', (e) => this.switchTab(e.target.dataset.tab));
         });
 
+        // 回复、修改和删除消息
+        document.querySelector('.messages-container').addEventListener('click', (e) => {
+            if (e.target.classList.contains('message-action')) {
+                this.onMessageAction(e.target.dataset.action, e.target.closest('.message'), e.target.dataset.emoji);
+            } else if (e.target.closest('.message-quote')) {
+                this.scrollToMessage(e.target.closest('.message').dataset.replyTo);
+            }
+        });
+        document.getElementById('cancel-reply-btn').addEventListener('click', () => this.cancelReply());
+
+        // 加载历史消息
+        document.querySelectorAll('.load-history').forEach(btn => {
+            btn.addEventListener('click', () => this.requestHistory(btn.dataset.area));
+        });
+
         // 选择私聊对象
         document.getElementById('private-target').addEventListener('change', (e) => {
+            if (e.target.value) this.requestHistory('private_chat');
             if (this.currentTab === 'private') {
                 document.getElementById('message-input').focus();
             }
         });
 
+        // 切回页面时将正在查看的私聊消息标记为已读
+        document.addEventListener('visibilitychange', () => this.flushReadReceipts());
+
         // 选择群聊组
         document.getElementById('group-target').addEventListener('change', (e) => {
-            if (this.currentTab === 'group') {
+            this.requestMembers(e.target.value);
+            if (e.target.value) this.requestHistory('group_chat');
+            if (this.currentTab === 'groups') {
                 document.getElementById('message-input').focus();
             }
         });
@@ -49,6 +91,12 @@ class ChatClient {
         // 创建群组
         document.getElementById('create-group-btn').addEventListener('click', () => this.showCreateGroup());
 
+        // 群组成员管理
+        document.getElementById('join-group-btn').addEventListener('click', () => this.joinGroup());
+        document.getElementById('invite-group-btn').addEventListener('click', () => this.inviteToGroup());
+        document.getElementById('leave-group-btn').addEventListener('click', () => this.leaveGroup());
+        document.getElementById('manage-group-btn').addEventListener('click', () => this.manageGroup());
+
         // 刷新群组
         document.getElementById('refresh-groups').addEventListener('click', () => this.requestGroups());
 
@@ -66,59 +114,95 @@ class ChatClient {
         });
     }
 
-    async login() {
+    readCredentials() {
         const username = document.getElementById('username').value.trim();
+        const password = document.getElementById('password').value;
         if (!username) {
             alert('请输入用户名');
-            return;
+            return null;
+        }
+        if (!password) {
+            alert('请输入密码');
+            return null;
         }
+        return { username, password };
+    }
 
-        this.currentUser = username;
+    async login() {
+        const credentials = this.readCredentials();
+        if (!credentials) return;
+
+        this.currentUser = credentials.username;
 
         try {
-            // 连接到WebSocket服务器
-            this.connectWebSocket(username);
-            
-            // 切换到聊天界面
-            document.getElementById('login-screen').classList.add('hidden');
-            document.getElementById('chat-screen').classList.remove('hidden');
-            document.getElementById('current-user').textContent = username;
+            // 连接到WebSocket服务器，收到登录确认后再切换到聊天界面
+            this.connectWebSocket(() => this.sendLogin(credentials));
         } catch (error) {
             console.error('登录失败:', error);
             alert('连接服务器失败，请稍后再试');
         }
     }
 
-    connectWebSocket(username) {
-        // WebSocket服务器地址 - 我们将在下一步实现这个后端
+    async register() {
+        const credentials = this.readCredentials();
+        if (!credentials) return;
+
+        this.currentUser = credentials.username;
+        // 注册成功后使用同一组账号密码自动登录
+        this.pendingLogin = credentials;
+
+        try {
+            this.connectWebSocket(() => {
+                this.sendWsMessage({
+                    name: credentials.username,
+                    op: 10, // enum.Register
+                    msg: credentials.password,
+                    area: "public_screen"
+                });
+            });
+        } catch (error) {
+            console.error('注册失败:', error);
+            alert('连接服务器失败，请稍后再试');
+        }
+    }
+
+    sendLogin(credentials) {
+        this.sendWsMessage({
+            name: credentials.username,
+            op: 3, // enum.Login
+            msg: credentials.password,
+            area: "public_screen"
+        });
+    }
+
+    connectWebSocket(onOpen) {
+        if (this.ws) {
+            this.ws.close();
+        }
+
         const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
         this.ws = new WebSocket(`${protocol}//${window.location.host}/ws`);
 
         this.ws.onopen = () => {
             console.log('已连接到聊天服务器');
-
-            // 发送登录消息
-            this.sendWsMessage({
-                name: username,
-                op: 3, // enum.Login
-                msg: '',
-                area: "public_screen"
-            });
+            onOpen();
         };
 
         this.ws.onmessage = (event) => {
-            // 服务器发送的是格式化的消息，如：【公屏】时间 [用户名]: 内容
-            // 我们需要在这里解析这些消息
-            const rawMessage = event.data;
-            
-            // 根据服务器格式解析消息
-            const parsedMsg = this.parseServerMessage(rawMessage);
-            this.handleReceivedMessage(parsedMsg);
+            // 服务器推送的是结构化的事件 JSON，直接解析即可
+            try {
+                this.handleReceivedMessage(JSON.parse(event.data));
+            } catch (error) {
+                console.error('解析服务器消息失败:', error);
+            }
         };
 
         this.ws.onclose = () => {
             console.log('与服务器断开连接');
-            alert('与服务器断开连接');
+            if (this.loggedIn) {
+                this.loggedIn = false;
+                alert('与服务器断开连接');
+            }
         };
 
         this.ws.onerror = (error) => {
@@ -126,60 +210,9 @@ class ChatClient {
         };
     }
 
-    parseServerMessage(rawMessage) {
-        // 解析服务器返回的消息格式
-        // 格式如："【公屏】2023-10-01 12:00:00 [Alice]: Hello World"
-        let message = rawMessage.trim();
-        
-        let area = 'public_screen';
-        let op = 1; // 默认是聊天消息
-        let name = 'SYSTEM';
-        let content = message;
-        let timestamp = Math.floor(Date.now() / 1000);
-
-        // 确定区域类型
-        if (message.startsWith('【公')) {
-            area = 'public_screen';
-            op = 1;
-        } else if (message.startsWith('【群')) {
-            area = 'group_chat';
-            op = 6; // GroupChat
-        } else if (message.startsWith('【私')) {
-            area = 'private_chat';
-            op = 5; // PrivateChat
-        }
-
-        // 解析内容部分
-        const parts = message.split('] ');
-        if (parts.length >= 2) {
-            const header = parts[0]; // 例如 "【公屏】2023-10-01 12:00:00 [Alice"
-            content = parts.slice(1).join('] '); // 余下的内容
-
-            // 提取用户名
-            const nameStart = header.lastIndexOf('[');
-            if (nameStart !== -1) {
-                name = header.substring(nameStart + 1); // 提取用户名
-
-                // 提取时间戳
-                const timeStart = header.indexOf('】') + 1;
-                const timeEnd = header.lastIndexOf('[');
-                if (timeStart !== 0 && timeEnd !== -1 && timeEnd > timeStart) {
-                    const timeStr = header.substring(timeStart, timeEnd).trim();
-                    const date = new Date(timeStr);
-                    if (!isNaN(date.getTime())) {
-                        timestamp = Math.floor(date.getTime() / 1000);
-                    }
-                }
-            }
-        }
-
-        return {
-            name: name,
-            op: op,
-            msg: content,
-            area: area,
-            timestamp: timestamp
-        };
+    nextCorrelationId() {
+        this.correlationSeq++;
+        return String(this.correlationSeq);
     }
 
     sendWsMessage(data) {
@@ -206,6 +239,8 @@ class ChatClient {
                     op: 1, // enum.Chat
                     msg: message,
                     area: "public_screen",
+                    correlation_id: this.nextCorrelationId(),
+                    reply_to: this.replyId('public_screen'),
                     timestamp: Math.floor(Date.now() / 1000)
                 });
                 break;
@@ -222,6 +257,8 @@ class ChatClient {
                     msg: message,
                     target: target,
                     area: "private_chat",
+                    correlation_id: this.nextCorrelationId(),
+                    reply_to: this.replyId('private_chat'),
                     timestamp: Math.floor(Date.now() / 1000)
                 });
                 break;
@@ -238,6 +275,8 @@ class ChatClient {
                     msg: message,
                     group: group,
                     area: "group_chat",
+                    correlation_id: this.nextCorrelationId(),
+                    reply_to: this.replyId('group_chat'),
                     timestamp: Math.floor(Date.now() / 1000)
                 });
                 break;
@@ -245,70 +284,402 @@ class ChatClient {
 
         input.value = '';
         input.focus();
+        this.cancelReply();
+    }
+
+    // 正在回复的消息属于 area 时返回其ID
+    replyId(area) {
+        return this.replyTo && this.replyTo.area === area ? this.replyTo.id : undefined;
+    }
+
+    // 回复消息，选中消息所在的群组或私聊对象，发送时带上 reply_to
+    startReply(div) {
+        if (div.dataset.area === 'group_chat') {
+            document.getElementById('group-target').value = div.dataset.group;
+        } else if (div.dataset.area === 'private_chat') {
+            document.getElementById('private-target').value = div.dataset.peer;
+        }
+        this.replyTo = { id: Number(div.dataset.id), area: div.dataset.area };
+        document.getElementById('reply-text').textContent = `回复 ${div.dataset.name}: ${div.dataset.msg}`;
+        document.getElementById('reply-bar').classList.remove('hidden');
+        document.getElementById('message-input').focus();
+    }
+
+    cancelReply() {
+        this.replyTo = null;
+        document.getElementById('reply-bar').classList.add('hidden');
+    }
+
+    scrollToMessage(id) {
+        const div = document.querySelector(`.message[data-id="${id}"]`);
+        if (!div) return;
+        div.scrollIntoView({ behavior: 'smooth', block: 'center' });
+        div.classList.add('highlight');
+        setTimeout(() => div.classList.remove('highlight'), 1500);
+    }
+
+    // 在原消息上显示回复数，展开后列出所有回复
+    updateThread(id) {
+        const parent = document.querySelector(`.message[data-id="${id}"]`);
+        if (!parent || !parent.querySelector('.thread-toggle')) return;
+        const count = document.querySelectorAll(`.message[data-reply-to="${id}"]`).length;
+        const toggle = parent.querySelector('.thread-toggle');
+        toggle.textContent = `${count} 条回复`;
+        toggle.classList.toggle('hidden', count === 0);
+
+        const thread = parent.querySelector('.message-thread');
+        if (!thread.classList.contains('hidden')) {
+            this.renderThread(parent);
+        }
+    }
+
+    toggleThread(parent) {
+        const thread = parent.querySelector('.message-thread');
+        thread.classList.toggle('hidden');
+        if (!thread.classList.contains('hidden')) {
+            this.renderThread(parent);
+        }
+    }
+
+    renderThread(parent) {
+        const thread = parent.querySelector('.message-thread');
+        thread.innerHTML = '';
+        document.querySelectorAll(`.message[data-reply-to="${parent.dataset.id}"]`).forEach(reply => {
+            const item = document.createElement('div');
+            item.className = 'thread-item';
+            item.textContent = `${reply.dataset.name}: ${reply.dataset.msg || '(已删除)'}`;
+            item.addEventListener('click', () => this.scrollToMessage(reply.dataset.id));
+            thread.appendChild(item);
+        });
     }
 
     handleReceivedMessage(data) {
-        // 检查是否是系统消息（包含用户列表或群组列表）
-        if (data.msg.includes('当前用户') || data.msg.includes('当前群组')) {
-            this.handleListResponse(data);
-            return;
+        switch (data.kind) {
+            case 'ping':
+                // 回复服务器的心跳，长时间不回复会被断开
+                this.sendWsMessage({ op: 24 }); // enum.Pong
+                return;
+            case 'pong':
+                return;
+            case 'login_ack':
+                this.onLoginSuccess(data.name, data.user || {});
+                if (data.pending) {
+                    this.displaySystemMessage(data, `你有 ${data.pending} 条离线消息`);
+                }
+                return;
+            case 'login_reject':
+                this.onLoginFailed(data.msg);
+                return;
+            case 'register_ack':
+                if (this.pendingLogin) {
+                    this.sendLogin(this.pendingLogin);
+                    this.pendingLogin = null;
+                }
+                return;
+            case 'register_reject':
+                this.pendingLogin = null;
+                this.onLoginFailed(data.msg, '注册失败');
+                return;
+            case 'user_list':
+                this.updateUsers((data.list || []).filter(u => u !== this.currentUser));
+                return;
+            case 'group_list':
+                this.updateGroups(data.list || []);
+                return;
+            case 'profile':
+                this.updateUserProfile(data.name, data.user || {});
+                return;
+            case 'history':
+                this.showHistory(data);
+                return;
+            case 'group_members':
+                this.updateMembers(data.group, data.list || [], data.roles || {});
+                return;
+            case 'group_join':
+                this.displaySystemMessage(data, data.name === data.target
+                    ? `${data.target} 加入了群组 ${data.group}`
+                    : `${data.name} 邀请 ${data.target} 加入了群组 ${data.group}`);
+                this.onMembershipChanged(data);
+                return;
+            case 'group_leave':
+                this.displaySystemMessage(data, data.name === data.target
+                    ? `${data.target} 退出了群组 ${data.group}`
+                    : `${data.target} 被 ${data.name} 移出了群组 ${data.group}`);
+                this.onMembershipChanged(data);
+                return;
+            case 'group_role':
+                this.displaySystemMessage(data, `${data.name} 将 ${data.target} 设为${this.roleName(data.msg)}`);
+                this.onMembershipChanged(data);
+                return;
+            case 'group_ban':
+                this.displaySystemMessage(data, `${data.target} 被 ${data.name} 封禁`);
+                this.onMembershipChanged(data);
+                return;
+            case 'group_unban':
+                this.displaySystemMessage(data, `${data.target} 被 ${data.name} 解除封禁`);
+                return;
+            case 'group_dissolve':
+                this.displaySystemMessage(data, `群组 ${data.group} 已被 ${data.name} 解散`);
+                this.onMembershipChanged(data);
+                return;
+            case 'login':
+                this.displaySystemMessage(data, `${data.name} 上线了`);
+                return;
+            case 'logout':
+                this.displaySystemMessage(data, `${data.name} 下线了`);
+                return;
+            case 'notice':
+            case 'error':
+                this.displaySystemMessage(data, data.msg);
+                return;
+            case 'ack':
+                this.showDeliveryStatus(data);
+                return;
+            case 'read_receipt':
+                this.showReadReceipt(data);
+                return;
+            case 'typing':
+                this.showTyping(data);
+                return;
+            case 'message_update':
+                this.applyUpdate(data);
+                return;
+            case 'reaction':
+                this.applyReactions(data);
+                return;
+            case 'shutdown':
+                // 服务器即将关闭，随后的断开不再弹窗提示
+                this.displaySystemMessage(data, data.msg);
+                this.loggedIn = false;
+                return;
         }
-        
-        // 显示消息
+
+        // 对方发出消息后不再显示其输入状态
+        if (data.kind === 'chat') {
+            this.clearTyping(data);
+        }
+
+        // 聊天消息按区域显示到对应的标签页
         let chatType = 'public';
-        if (data.area === 'private_chat' || data.op === 5) {
+        if (data.area === 'private_chat') {
             chatType = 'private';
-        } else if (data.area === 'group_chat' || data.op === 6) {
+        } else if (data.area === 'group_chat') {
             chatType = 'group';
         }
-        
+
         this.displayMessage(data, chatType);
     }
 
-    handleListResponse(data) {
-        // 解析响应消息，例如 "当前在线用户列表:\n- user1\n- user2"
-        if (data.msg.includes('当前在线用户列表:')) {
-            const users = this.parseUserList(data.msg);
-            this.updateUsers(users);
-        } else if (data.msg.includes('当前群组列表:')) {
-            const groups = this.parseGroupList(data.msg);
-            this.updateGroups(groups);
+    onLoginSuccess(username, userInfo) {
+        this.loggedIn = true;
+        this.currentUser = username;
+        document.getElementById('password').value = '';
+        this.updateUserProfile(username, userInfo);
+        this.requestHistory('public_screen');
+
+        // 切换到聊天界面
+        document.getElementById('login-screen').classList.add('hidden');
+        document.getElementById('chat-screen').classList.remove('hidden');
+        document.getElementById('current-user').textContent = username;
+    }
+
+    onLoginFailed(reason, title = '登录失败') {
+        alert(`${title}: ${reason}`);
+        if (this.ws) {
+            this.ws.close();
+            this.ws = null;
         }
+        this.currentUser = '';
     }
 
-    parseUserList(response) {
-        // 解析用户列表 "当前在线用户列表:\n- user1\n- user2"
-        const lines = response.split('\n');
-        const users = [];
-        for (let i = 1; i < lines.length; i++) {  // 从第2行开始是用户列表
-            const line = lines[i].trim();
-            if (line.startsWith('- ')) {
-                const username = line.substring(2).trim();
-                if (username && username !== this.currentUser) {
-                    users.push(username);
-                }
+    displaySystemMessage(data, text) {
+        let chatType = 'public';
+        if (data.area === 'private_chat') {
+            chatType = 'private';
+        } else if (data.area === 'group_chat') {
+            chatType = 'group';
+        }
+
+        this.displayMessage({
+            name: data.kind === 'error' ? '错误' : '系统',
+            msg: text,
+            timestamp: data.timestamp,
+            area: data.area
+        }, chatType);
+    }
+
+    // 在自己发出的消息下方显示投递状态，发送失败时显示失败原因
+    showDeliveryStatus(data) {
+        if (data.status === 'failed') {
+            this.displaySystemMessage(data, `消息发送失败: ${data.msg}`);
+            return;
+        }
+        const status = document.querySelector(`.message[data-id="${data.id}"] .message-status`);
+        if (!status) return;
+        if (data.status === 'delivered') {
+            status.textContent = `已送达 ${data.delivered} 人`;
+        } else if (data.area === 'public_screen') {
+            status.textContent = '已发送，当前没有其他在线用户';
+        } else {
+            status.textContent = '已发送，接收者上线后送达';
+        }
+    }
+
+    // 在私聊和群聊中输入时通知对方，服务端每 2 秒最多转发一次，这里按相同间隔发送
+    sendTyping() {
+        const now = Date.now();
+        if (now - this.lastTypingSent < TYPING_INTERVAL) return;
+
+        const request = {
+            name: this.currentUser,
+            op: 26, // enum.Typing
+            timestamp: Math.floor(now / 1000)
+        };
+        if (this.currentTab === 'private') {
+            request.area = 'private_chat';
+            request.target = document.getElementById('private-target').value;
+            if (!request.target) return;
+        } else if (this.currentTab === 'groups') {
+            request.area = 'group_chat';
+            request.group = document.getElementById('group-target').value;
+            if (!request.group) return;
+        } else {
+            return;
+        }
+        this.sendWsMessage(request);
+        this.lastTypingSent = now;
+    }
+
+    typingKey(data) {
+        return data.area === 'group_chat' ? `group:${data.group}:${data.name}` : `private:${data.name}`;
+    }
+
+    // 显示“正在输入”，一段时间内没有收到新的输入状态或收到对方的消息后隐藏
+    showTyping(data) {
+        const key = this.typingKey(data);
+        const current = this.typingUsers[key];
+        if (current) clearTimeout(current.timer);
+        this.typingUsers[key] = {
+            data: data,
+            timer: setTimeout(() => this.clearTyping(data), TYPING_TIMEOUT)
+        };
+        this.renderTyping();
+    }
+
+    clearTyping(data) {
+        const key = this.typingKey(data);
+        const current = this.typingUsers[key];
+        if (!current) return;
+        clearTimeout(current.timer);
+        delete this.typingUsers[key];
+        this.renderTyping();
+    }
+
+    // 只显示当前标签页对应区域的输入状态
+    renderTyping() {
+        const area = this.currentTab === 'private' ? 'private_chat'
+            : this.currentTab === 'groups' ? 'group_chat' : '';
+        const names = Object.values(this.typingUsers)
+            .filter(item => item.data.area === area)
+            .map(item => area === 'group_chat' ? `[${item.data.group}] ${item.data.name}` : item.data.name);
+        document.getElementById('typing-indicator').textContent =
+            names.length > 0 ? `${names.join('、')} 正在输入…` : '';
+    }
+
+    // 对方已读 data.id 及之前的私聊消息，更新自己发给对方的消息的状态
+    showReadReceipt(data) {
+        const readAt = new Date(data.timestamp * 1000).toLocaleString();
+        document.querySelectorAll(`.message.own[data-peer="${CSS.escape(data.name)}"]`).forEach(div => {
+            if (Number(div.dataset.id) <= data.id) {
+                div.querySelector('.message-status').textContent = `已读 ${readAt}`;
             }
+        });
+    }
+
+    // 记录对方发来的私聊消息，正在查看私聊页面时立即发送已读回执
+    trackUnread(data) {
+        if (data.kind !== 'chat' || data.area !== 'private_chat' || data.name === this.currentUser) return;
+        if (data.id > (this.unread[data.name] || 0)) {
+            this.unread[data.name] = data.id;
         }
-        return users;
+        this.flushReadReceipts();
     }
 
-    parseGroupList(response) {
-        // 解析群组列表 "当前群组列表:\n- group1\n- group2"
-        const lines = response.split('\n');
-        const groups = [];
-        for (let i = 1; i < lines.length; i++) {  // 从第2行开始是群组列表
-            const line = lines[i].trim();
-            if (line.startsWith('- ')) {
-                const groupName = line.substring(2).trim();
-                if (groupName) {
-                    groups.push(groupName);
-                }
+    flushReadReceipts() {
+        if (this.currentTab !== 'private' || document.visibilityState !== 'visible') return;
+        for (const [peer, id] of Object.entries(this.unread)) {
+            // 关闭已读回执时服务端也会忽略，这里直接不发送
+            if (!this.profile.hide_read_receipts && id > (this.readUpTo[peer] || 0)) {
+                this.sendWsMessage({
+                    name: this.currentUser,
+                    op: 25, // enum.MarkRead
+                    id: id,
+                    target: peer,
+                    area: "private_chat",
+                    timestamp: Math.floor(Date.now() / 1000)
+                });
+                this.readUpTo[peer] = id;
             }
         }
-        return groups;
+        this.unread = {};
     }
 
-    displayMessage(data, chatType) {
+    conversationKey(area, data) {
+        if (area === 'private_chat') {
+            const peer = data.name === this.currentUser ? data.target : data.name;
+            return `private:${peer}`;
+        }
+        if (area === 'group_chat') {
+            return `group:${data.group}`;
+        }
+        return 'public';
+    }
+
+    requestHistory(area) {
+        const request = {
+            name: this.currentUser,
+            op: 22, // enum.FetchHistory
+            area: area,
+            timestamp: Math.floor(Date.now() / 1000)
+        };
+
+        let key = 'public';
+        if (area === 'private_chat') {
+            request.target = document.getElementById('private-target').value;
+            if (!request.target) {
+                alert('请选择私聊对象');
+                return;
+            }
+            key = `private:${request.target}`;
+        } else if (area === 'group_chat') {
+            request.group = document.getElementById('group-target').value;
+            if (!request.group) {
+                alert('请选择群组');
+                return;
+            }
+            key = `group:${request.group}`;
+        }
+
+        // 从已加载的最早一条消息继续向前翻页
+        request.msg = JSON.stringify({ limit: 20, before_id: this.oldestIds[key] || 0 });
+        this.sendWsMessage(request);
+    }
+
+    showHistory(data) {
+        const chatType = data.area === 'private_chat' ? 'private'
+            : data.area === 'group_chat' ? 'group' : 'public';
+        const records = data.history || [];
+        if (records.length === 0) {
+            this.displaySystemMessage(data, '没有更多历史消息');
+            return;
+        }
+        // 从新到旧依次插入到列表顶部，最终按时间顺序排列
+        for (let i = records.length - 1; i >= 0; i--) {
+            this.displayMessage(records[i], chatType, true);
+        }
+    }
+
+    displayMessage(data, chatType, prepend = false) {
         let containerId = `${chatType}-chat`;
         if (chatType === 'public') containerId = 'public-chat';
         else if (chatType === 'private') containerId = 'private-chat';
@@ -317,20 +688,189 @@ class ChatClient {
         const container = document.getElementById(containerId);
         if (!container) return;
 
+        if (data.id) {
+            if (this.seenIds.has(data.id)) return;
+            this.seenIds.add(data.id);
+
+            const key = this.conversationKey(data.area, data);
+            if (!this.oldestIds[key] || data.id < this.oldestIds[key]) {
+                this.oldestIds[key] = data.id;
+            }
+        }
+
         const div = document.createElement('div');
         div.className = `message ${data.name === this.currentUser ? 'own' : ''}`;
+        if (data.id) {
+            div.dataset.id = data.id;
+        }
+        if (data.kind === 'chat') {
+            // 回复和已读回执需要知道消息所在的会话
+            div.dataset.name = data.name;
+            div.dataset.area = data.area;
+            div.dataset.group = data.group || '';
+            if (data.area === 'private_chat') {
+                div.dataset.peer = data.name === this.currentUser ? data.target : data.name;
+            }
+        }
+        if (data.reply_to) {
+            div.dataset.replyTo = data.reply_to;
+        }
         
+        let sender = data.name;
+        if (data.kind === 'chat' && data.area === 'private_chat') {
+            sender = `${data.name} -> ${data.target}`;
+        } else if (data.kind === 'chat' && data.area === 'group_chat') {
+            sender = `[${data.group}] ${data.name}`;
+        }
+
         const timeStr = new Date(data.timestamp * 1000).toLocaleString();
         div.innerHTML = `
             <div class="message-header">
-                <span class="message-sender">${this.escapeHtml(data.name)}</span>
+                <span class="message-sender">${this.escapeHtml(sender)}</span>
                 <span class="message-time">${timeStr}</span>
             </div>
-            <div class="message-content">${this.escapeHtml(data.msg)}</div>
+            ${this.quoteHtml(data)}
+            <div class="message-content">${this.contentHtml(data)}</div>
+            <div class="message-reactions"></div>
+            <div class="message-status"></div>
+            ${this.actionsHtml(data)}
+            ${data.kind === 'chat' && data.id ? `
+            <button class="message-action thread-toggle hidden" data-action="thread"></button>
+            <div class="message-thread hidden"></div>` : ''}
         `;
+        div.dataset.msg = data.msg || '';
+        this.renderReactions(div, data);
 
-        container.appendChild(div);
-        container.scrollTop = container.scrollHeight;
+        this.trackUnread(data);
+
+        if (prepend) {
+            // 插入到“加载更早的消息”按钮之后
+            const loadButton = container.querySelector('.load-history');
+            container.insertBefore(div, loadButton ? loadButton.nextSibling : container.firstChild);
+        } else {
+            container.appendChild(div);
+            container.scrollTop = container.scrollHeight;
+        }
+
+        // 加载历史时原消息可能晚于回复显示，两边都要更新回复数
+        if (data.reply_to) this.updateThread(data.reply_to);
+        if (data.id) this.updateThread(data.id);
+    }
+
+    // 回复的消息显示引用的原消息，点击跳转到原消息
+    quoteHtml(data) {
+        if (!data.quote) return '';
+        return `<div class="message-quote">↳ 回复 ${this.escapeHtml(data.quote.name)}: ${this.escapeHtml(data.quote.msg)}</div>`;
+    }
+
+    // 消息内容，修改过的消息带上标记，已删除的消息只显示删除者
+    contentHtml(data) {
+        if (data.deleted) {
+            return `<span class="message-deleted">消息已被 ${this.escapeHtml(data.deleted_by)} 删除</span>`;
+        }
+        const edited = data.edited_at ? ' <span class="message-edited">(已编辑)</span>' : '';
+        return this.escapeHtml(data.msg) + edited;
+    }
+
+    // 自己的消息可以修改和删除，群主和管理员还可以删除群成员的消息，最终由服务端校验
+    actionsHtml(data) {
+        if (data.kind !== 'chat' || !data.id || data.deleted) return '';
+        const own = data.name === this.currentUser;
+        const roles = data.area === 'group_chat' ? (this.groupRoles[data.group] || {}) : {};
+        const moderator = roles[this.currentUser] === 'owner' || roles[this.currentUser] === 'admin';
+
+        let buttons = '<button class="message-action" data-action="reply">回复</button>';
+        buttons += '<button class="message-action" data-action="pick">回应</button>';
+        if (own) buttons += '<button class="message-action" data-action="edit">编辑</button>';
+        if (own || moderator) buttons += '<button class="message-action" data-action="delete">删除</button>';
+        const picker = REACTION_EMOJIS
+            .map(emoji => `<button class="message-action" data-action="react" data-emoji="${emoji}">${emoji}</button>`)
+            .join('');
+        return `<div class="message-actions">${buttons}<span class="reaction-picker hidden">${picker}</span></div>`;
+    }
+
+    // 每种表情及回应人数，自己回应过的表情高亮，点击切换
+    // 表情和用户名由其他用户提供，只通过 DOM 属性设置，不拼接到 HTML 中
+    renderReactions(div, data) {
+        const container = div.querySelector('.message-reactions');
+        container.replaceChildren();
+        Object.entries(data.reactions || {}).forEach(([emoji, users]) => {
+            const button = document.createElement('button');
+            button.className = 'message-action reaction';
+            button.classList.toggle('mine', users.includes(this.currentUser));
+            button.dataset.action = 'toggle-reaction';
+            button.dataset.emoji = emoji;
+            button.title = users.join('、');
+            button.textContent = `${emoji} ${users.length}`;
+            container.appendChild(button);
+        });
+    }
+
+    applyReactions(data) {
+        const div = document.querySelector(`.message[data-id="${data.id}"]`);
+        if (!div) return;
+        this.renderReactions(div, data);
+    }
+
+    sendReaction(div, emoji, add) {
+        this.sendWsMessage({
+            name: this.currentUser,
+            op: add ? 29 : 30, // enum.React / enum.Unreact
+            id: Number(div.dataset.id),
+            msg: emoji,
+            timestamp: Math.floor(Date.now() / 1000)
+        });
+    }
+
+    onMessageAction(action, div, emoji) {
+        const id = Number(div.dataset.id);
+        if (action === 'reply') {
+            this.startReply(div);
+        } else if (action === 'thread') {
+            this.toggleThread(div);
+        } else if (action === 'pick') {
+            div.querySelector('.reaction-picker').classList.toggle('hidden');
+        } else if (action === 'react') {
+            div.querySelector('.reaction-picker').classList.add('hidden');
+            this.sendReaction(div, emoji, true);
+        } else if (action === 'toggle-reaction') {
+            const mine = [...div.querySelectorAll('.reaction.mine')].some(btn => btn.dataset.emoji === emoji);
+            this.sendReaction(div, emoji, !mine);
+        } else if (action === 'edit') {
+            const msg = prompt('修改消息:', div.dataset.msg);
+            if (msg === null || !msg.trim() || msg === div.dataset.msg) return;
+            this.sendWsMessage({
+                name: this.currentUser,
+                op: 27, // enum.EditMessage
+                id: id,
+                msg: msg,
+                timestamp: Math.floor(Date.now() / 1000)
+            });
+        } else if (action === 'delete') {
+            if (!confirm('确定要删除这条消息吗？')) return;
+            this.sendWsMessage({
+                name: this.currentUser,
+                op: 28, // enum.DeleteMessage
+                id: id,
+                timestamp: Math.floor(Date.now() / 1000)
+            });
+        }
+    }
+
+    // 按 ID 替换已显示的消息内容
+    applyUpdate(data) {
+        const div = document.querySelector(`.message[data-id="${data.id}"]`);
+        if (!div) return;
+        div.querySelector('.message-content').innerHTML = this.contentHtml(data);
+        this.renderReactions(div, data);
+        div.dataset.msg = data.msg || '';
+        if (data.deleted) {
+            const actions = div.querySelector('.message-actions');
+            if (actions) actions.remove();
+        }
+        if (div.dataset.replyTo) {
+            this.updateThread(div.dataset.replyTo);
+        }
     }
 
     escapeHtml(text) {
@@ -347,17 +887,22 @@ class ChatClient {
 
         // 显示对应的聊天面板
         document.querySelectorAll('.chat-messages').forEach(panel => {
-            panel.classList.toggle('hidden', !panel.id.includes(tabName));
+            // 群聊标签页对应的面板是 group-chat
+            const panelId = tabName === 'groups' ? 'group-chat' : `${tabName}-chat`;
+            panel.classList.toggle('hidden', panel.id !== panelId);
             if (!panel.classList.contains('hidden')) {
                 panel.scrollTop = panel.scrollHeight;
             }
         });
 
         this.currentTab = tabName;
+        this.renderTyping();
+        this.cancelReply();
 
         // 根据当前标签页可能需要执行特定操作
         if (tabName === 'private') {
             this.requestUsers();
+            this.flushReadReceipts();
         } else if (tabName === 'groups') {
             this.requestGroups();
         }
@@ -431,6 +976,7 @@ class ChatClient {
 
         // 更新群聊下拉列表
         const groupSelect = document.getElementById('group-target');
+        const selected = groupSelect.value;
         groupSelect.innerHTML = '<option value="">选择群组</option>';
         groups.forEach(group => {
             const option = document.createElement('option');
@@ -438,6 +984,8 @@ class ChatClient {
             option.textContent = group;
             groupSelect.appendChild(option);
         });
+        // 保留之前选中的群组
+        groupSelect.value = groups.includes(selected) ? selected : '';
 
         // 重新绑定刷新按钮事件
         document.getElementById('refresh-groups').addEventListener('click', () => this.requestGroups());
@@ -456,6 +1004,138 @@ class ChatClient {
         }
     }
 
+    selectedGroup() {
+        const group = document.getElementById('group-target').value;
+        if (!group) {
+            alert('请选择群组');
+        }
+        return group;
+    }
+
+    joinGroup() {
+        const groupName = prompt('请输入要加入的群组名称:');
+        if (groupName && groupName.trim()) {
+            this.sendWsMessage({
+                name: this.currentUser,
+                op: 11, // enum.JoinGroup
+                group: groupName.trim(),
+                area: "group_chat",
+                timestamp: Math.floor(Date.now() / 1000)
+            });
+        }
+    }
+
+    leaveGroup() {
+        const group = this.selectedGroup();
+        if (!group || !confirm(`确定退出群组 ${group} 吗?`)) return;
+
+        this.sendWsMessage({
+            name: this.currentUser,
+            op: 12, // enum.LeaveGroup
+            group: group,
+            area: "group_chat",
+            timestamp: Math.floor(Date.now() / 1000)
+        });
+    }
+
+    inviteToGroup() {
+        const group = this.selectedGroup();
+        if (!group) return;
+
+        const target = prompt(`请输入要邀请加入 ${group} 的用户名:`);
+        if (target && target.trim()) {
+            this.sendWsMessage({
+                name: this.currentUser,
+                op: 13, // enum.InviteToGroup
+                group: group,
+                target: target.trim(),
+                area: "group_chat",
+                timestamp: Math.floor(Date.now() / 1000)
+            });
+        }
+    }
+
+    requestMembers(group) {
+        if (!group) {
+            this.updateMembers('', []);
+            return;
+        }
+        this.sendWsMessage({
+            name: this.currentUser,
+            op: 14, // enum.ListGroupMembers
+            group: group,
+            area: "group_chat",
+            timestamp: Math.floor(Date.now() / 1000)
+        });
+    }
+
+    updateMembers(group, members, roles = {}) {
+        if (group) this.groupRoles[group] = roles;
+
+        // 只显示当前选中群组的成员
+        if (group && group !== document.getElementById('group-target').value) return;
+
+        document.getElementById('members-group').textContent = group ? `(${group})` : '';
+        const membersList = document.getElementById('members-list');
+        membersList.innerHTML = '';
+        members.forEach(member => {
+            const li = document.createElement('li');
+            li.textContent = roles[member] && roles[member] !== 'member'
+                ? `${member} (${this.roleName(roles[member])})`
+                : member;
+            membersList.appendChild(li);
+        });
+    }
+
+    roleName(role) {
+        return { owner: '群主', admin: '管理员', member: '成员' }[role] || '成员';
+    }
+
+    manageGroup() {
+        const group = this.selectedGroup();
+        if (!group) return;
+
+        // 群管理操作，target 表示是否需要输入目标用户
+        const actions = [
+            { title: '设为管理员', op: 15, target: true }, // enum.PromoteAdmin
+            { title: '取消管理员', op: 16, target: true }, // enum.DemoteAdmin
+            { title: '移出成员', op: 17, target: true },   // enum.KickMember
+            { title: '封禁用户', op: 18, target: true },   // enum.BanMember
+            { title: '解除封禁', op: 19, target: true },   // enum.UnbanMember
+            { title: '转让群主', op: 20, target: true },   // enum.TransferGroup
+            { title: '解散群组', op: 21, target: false }   // enum.DissolveGroup
+        ];
+        const menu = actions.map((a, i) => `${i + 1} - ${a.title}`).join('\n');
+        const choice = parseInt(prompt(`请选择对群组 ${group} 的管理操作:\n${menu}`), 10);
+        const action = actions[choice - 1];
+        if (!action) return;
+
+        let target = '';
+        if (action.target) {
+            target = (prompt(`${action.title}: 请输入目标用户名`) || '').trim();
+            if (!target) return;
+        } else if (!confirm(`确定${action.title} ${group} 吗?`)) {
+            return;
+        }
+
+        this.sendWsMessage({
+            name: this.currentUser,
+            op: action.op,
+            group: group,
+            target: target,
+            area: "group_chat",
+            timestamp: Math.floor(Date.now() / 1000)
+        });
+    }
+
+    onMembershipChanged(data) {
+        // 群组列表和当前群组的成员列表可能都已变化
+        this.requestGroups();
+        if (data.group === document.getElementById('group-target').value) {
+            this.requestMembers(data.group);
+        }
+    }
+
     logout() {
         if (this.ws) {
             this.sendWsMessage({
@@ -467,6 +1147,7 @@ class ChatClient {
             });
             this.ws.close();
         }
+        this.loggedIn = false;
         
         // 返回登录界面
         document.getElementById('chat-screen').classList.add('hidden');
@@ -476,6 +1157,10 @@ class ChatClient {
     }
 
     showProfileModal() {
+        // 服务端用提交的资料整体替换原资料，因此预先填入当前的值，只修改其中一项时其他项保持不变
+        document.getElementById('new-age').value = this.profile.age || '';
+        document.getElementById('new-sex').value = this.profile.sex || '';
+        document.getElementById('new-receipts').checked = !this.profile.hide_read_receipts;
         document.getElementById('profile-modal').classList.remove('hidden');
         document.getElementById('new-age').focus();
     }
@@ -489,16 +1174,19 @@ class ChatClient {
     updateProfile() {
         const age = document.getElementById('new-age').value.trim();
         const sex = document.getElementById('new-sex').value.trim();
+        const receipts = document.getElementById('new-receipts').checked;
 
-        if (!age && !sex) {
-            alert('请至少填写一项资料');
+        if (age === (this.profile.age || '') && sex === (this.profile.sex || '') &&
+            receipts === !this.profile.hide_read_receipts) {
+            alert('资料没有修改');
             return;
         }
 
         // 创建用户对象
         const user = {
             age: age,
-            sex: sex
+            sex: sex,
+            hide_read_receipts: !receipts
         };
 
         this.sendWsMessage({
@@ -516,6 +1204,8 @@ class ChatClient {
         if (username === this.currentUser) {
             document.getElementById('user-age').textContent = userInfo.age || '-';
             document.getElementById('user-sex').textContent = userInfo.sex || '-';
+            document.getElementById('user-receipts').textContent = userInfo.hide_read_receipts ? '关闭' : '开启';
+            this.profile = userInfo;
         }
     }
 }